@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cluster is a named connection with read/write splitting: the named
+// connection is the master and reads are routed to its slaves. It implements
+// readWriteRouter for the routing plugin installed on the master.
+type cluster struct {
+	name     string
+	master   *gorm.DB
+	strategy string
+
+	mu       sync.Mutex       // Guards config, replicas, their routing state and selector
+	config   ConnectionConfig // Resolved config of the master, inherited by the slaves
+	replicas []*replica       // In ConnectionConfig.Slaves order
+	selector ReplicaSelector
+}
+
+// validateCluster checks the slaves of a named connection.
+func validateCluster(c ConnectionConfig) error {
+	if len(c.Slaves) == 0 {
+		return nil
+	}
+	if _, err := newReplicaSelector(c.SlaveStrategy); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(c.Slaves))
+	for i, slave := range c.Slaves {
+		if slave.Driver != "" && !isDriverRegistered(slave.Driver) {
+			return fmt.Errorf("slave %d: unsupported driver: %s", i, slave.Driver)
+		}
+		if len(slave.Slaves) > 0 {
+			return fmt.Errorf("slave %d: slaves are only supported on named connections", i)
+		}
+		name := replicaName(slave, i)
+		if names[name] {
+			return fmt.Errorf("slave %d: duplicate replica name: %s", i, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// connectNamed opens a named connection. For a connection with slaves it also
+// connects the slaves and, with AutoRouting, installs the routing plugin on
+// the master; the returned cluster is nil for a single node. Slaves that fail
+// to connect stay in the cluster as failed, like the slaves of the primary.
+func (m *Manager) connectNamed(ctx context.Context, name string, config ConnectionConfig) (*gorm.DB, *cluster, error) {
+	db, err := m.connectNode(ctx, name, config)
+	if err != nil || len(config.Slaves) == 0 {
+		return db, nil, err
+	}
+
+	// The strategy name was checked by Validate
+	selector, _ := newReplicaSelector(config.SlaveStrategy)
+	settings := m.settings()
+	master := settings.inherit(config)
+	c := &cluster{name: name, master: db, strategy: config.SlaveStrategy, config: master, selector: selector}
+	connected := 0
+	for i, slaveConfig := range config.Slaves {
+		r := newReplica(replicaName(slaveConfig, i), slaveConfig)
+		c.replicas = append(c.replicas, r)
+
+		slave, err := openConnection(ctx, name+"/"+r.name, inheritConnection(master, slaveConfig), settings, m.logger)
+		if err != nil {
+			if ctx.Err() != nil {
+				closeReplicas(c.replicas)
+				if sqlDB, dbErr := db.DB(); dbErr == nil {
+					_ = sqlDB.Close()
+				}
+				return nil, nil, fmt.Errorf("failed to connect to slave %s: %w", r.name, err)
+			}
+			m.logWarn("Failed to connect to slave", "name", name, "slave", r.name, "error", err)
+			r.state, r.err = ReplicaFailed, err
+			continue
+		}
+		r.db, r.state = slave, ReplicaConnected
+		r.inRotation = true
+		connected++
+	}
+
+	if connected == 0 {
+		m.logWarn("No slaves available, using master for reads", "name", name)
+	}
+
+	if settings.AutoRouting {
+		_ = db.Use(&ReadWritePlugin{manager: m, router: c})
+	}
+
+	return db, c, nil
+}
+
+// masterDB implements readWriteRouter.
+func (c *cluster) masterDB() *gorm.DB {
+	return c.master
+}
+
+// slaveByName implements readWriteRouter.
+func (c *cluster) slaveByName(name string) *gorm.DB {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range c.replicas {
+		if r.name == name && r.state == ReplicaConnected {
+			return r.db
+		}
+	}
+	return nil
+}
+
+// readSlave implements readWriteRouter.
+func (c *cluster) readSlave() (*gorm.DB, *replica) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := selectReplica(c.selector, c.replicas)
+	if r == nil {
+		return nil, nil
+	}
+	return r.db, r
+}
+
+// observeSlaveLatency implements readWriteRouter.
+func (c *cluster) observeSlaveLatency(r *replica, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.observe(latency)
+}
+
+// read returns a slave for a read, or the master when no slave is available.
+func (c *cluster) read() *gorm.DB {
+	if slave, r := c.readSlave(); slave != nil {
+		r.reads.Done()
+		return slave
+	}
+	return c.master
+}
+
+// replicaSnapshot returns copies of the slaves of the cluster.
+func (c *cluster) replicaSnapshot() []replica {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	replicas := make([]replica, len(c.replicas))
+	for i, r := range c.replicas {
+		replicas[i] = *r
+	}
+	return replicas
+}
+
+// connectedReplicas returns copies of the connected slaves of the cluster.
+func (c *cluster) connectedReplicas() []replica {
+	var replicas []replica
+	for _, r := range c.replicaSnapshot() {
+		if r.connected() {
+			replicas = append(replicas, r)
+		}
+	}
+	return replicas
+}
+
+// connectedReplica returns the connected slave with the given name, or nil.
+// mu must be held.
+func (c *cluster) connectedReplica(name string) *replica {
+	for _, r := range c.replicas {
+		if r.name == name && r.connected() {
+			return r
+		}
+	}
+	return nil
+}
+
+// slaveConfig returns the config of a slave with the settings it inherits.
+func (c *cluster) slaveConfig(r replica) ConnectionConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return inheritConnection(c.config, r.config)
+}
+
+// replicaKey returns the name of a slave in the health check and stats maps.
+func (c *cluster) replicaKey(name string) string {
+	return c.name + "/" + name
+}
+
+// clusterSnapshot returns the named connections with slaves.
+func (m *Manager) clusterSnapshot() []*cluster {
+	m.connMu.RLock()
+	defer m.connMu.RUnlock()
+
+	clusters := make([]*cluster, 0, len(m.clusters))
+	for _, c := range m.clusters {
+		clusters = append(clusters, c)
+	}
+	return clusters
+}
+
+// recordClusterHealth updates the state of a slave of a named connection with
+// a health check result, like recordSlaveHealth.
+func (m *Manager) recordClusterHealth(c *cluster, name string, health ConnectionHealth) {
+	failureThreshold, recoveryThreshold := m.settings().healthThresholds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state := c.connectedReplica(name); state != nil {
+		m.applySlaveHealth(state, c.replicaKey(name), health, failureThreshold, recoveryThreshold)
+	}
+}
+
+// recordClusterLag updates the lag state of a slave of a named connection,
+// like recordReplicationLag.
+func (m *Manager) recordClusterLag(c *cluster, name string, lag time.Duration, err error) {
+	maxLag := m.settings().MaxReplicationLag
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state := c.connectedReplica(name); state != nil {
+		m.applyReplicationLag(state, c.replicaKey(name), lag, err, maxLag)
+	}
+}
+
+// reloadCluster applies a new config with the same slaves to a cluster: the pool
+// limits of the slaves and the slave strategy. config must be resolved.
+func (m *Manager) reloadCluster(c *cluster, config ConnectionConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config = config
+
+	for i, r := range c.replicas {
+		if i >= len(config.Slaves) {
+			break
+		}
+		r.config = config.Slaves[i]
+		m.reloadPool(r.db, inheritConnection(config, r.config))
+	}
+
+	if config.SlaveStrategy != c.strategy {
+		// The strategy name was checked by Validate
+		c.selector, _ = newReplicaSelector(config.SlaveStrategy)
+		c.strategy = config.SlaveStrategy
+	}
+}
+
+// close takes the slaves out of rotation, waits for the reads routed to them
+// and their connections in use and closes their connections. The master is closed by the caller.
+func (c *cluster) close() error {
+	c.mu.Lock()
+	replicas := c.replicas
+	c.replicas = nil
+	c.mu.Unlock()
+
+	var errs []error
+	for _, r := range replicas {
+		r.reads.Wait()
+		if r.db == nil {
+			continue
+		}
+		if sqlDB, err := r.db.DB(); err == nil {
+			waitPoolIdle(sqlDB)
+			if err := sqlDB.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close slave %s: %w", r.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,366 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newClusterManager creates a manager with an "analytics" named connection
+// whose master and two slaves each hold a single row naming the database.
+func newClusterManager(t *testing.T, configure func(*Config)) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:      "sqlite",
+		FilePath:    filepath.Join(dir, "primary.db"),
+		AutoRouting: true,
+		Connections: map[string]ConnectionConfig{
+			"analytics": {
+				FilePath: filepath.Join(dir, "analytics.db"),
+				Slaves: []ConnectionConfig{
+					{Name: "replica_a", FilePath: filepath.Join(dir, "replica_a.db")},
+					{Name: "replica_b", FilePath: filepath.Join(dir, "replica_b.db")},
+				},
+			},
+		},
+	}
+	if configure != nil {
+		configure(&config)
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+
+	c := manager.cluster("analytics")
+	require.NotNil(t, c)
+	seed := map[string]*gorm.DB{"analytics": c.master}
+	for _, r := range c.replicas {
+		seed[r.name] = r.db
+	}
+	for name, db := range seed {
+		db = db.Session(&gorm.Session{Context: SkipRouting(context.Background())})
+		require.NoError(t, db.AutoMigrate(&stickyItem{}))
+		require.NoError(t, db.Create(&stickyItem{Name: name}).Error)
+	}
+	return manager
+}
+
+// TestManager_Cluster_AutoRouting tests that a named connection with slaves routes like the primary
+func TestManager_Cluster_AutoRouting(t *testing.T) {
+	manager := newClusterManager(t, nil)
+	db := manager.Connection("analytics")
+
+	// Round-robin over the slaves
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		source, err := findSource(context.Background(), db)
+		require.NoError(t, err)
+		seen[source]++
+	}
+	assert.Equal(t, map[string]int{"replica_a": 2, "replica_b": 2}, seen)
+
+	// Routing hints work like for the primary
+	source, err := findSource(UseMaster(context.Background()), db)
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", source)
+
+	source, err = findSource(UseReplica(context.Background(), "replica_b"), db)
+	require.NoError(t, err)
+	assert.Equal(t, "replica_b", source)
+
+	// Writes go to the master
+	require.NoError(t, db.Create(&stickyItem{Name: "written"}).Error)
+	var count int64
+	require.NoError(t, manager.ConnectionWrite("analytics").Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	// The primary connection is not affected
+	assert.Nil(t, manager.cluster("primary"))
+	var tables int64
+	require.NoError(t, manager.DB().Raw("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sticky_items'").Scan(&tables).Error)
+	assert.Equal(t, int64(0), tables)
+}
+
+// TestManager_ConnectionReadWrite tests explicit access to the slaves and master of a named connection
+func TestManager_ConnectionReadWrite(t *testing.T) {
+	manager := newClusterManager(t, func(c *Config) {
+		c.AutoRouting = false
+		analytics := c.Connections["analytics"]
+		analytics.SlaveStrategy = StrategyRoundRobin
+		c.Connections["analytics"] = analytics
+	})
+
+	// Without AutoRouting the named connection is the master
+	source, err := findSource(context.Background(), manager.Connection("analytics"))
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", source)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		source, err := findSource(context.Background(), manager.ConnectionRead("analytics"))
+		require.NoError(t, err)
+		seen[source] = true
+	}
+	assert.Equal(t, map[string]bool{"replica_a": true, "replica_b": true}, seen)
+
+	source, err = findSource(context.Background(), manager.ConnectionWrite("analytics"))
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", source)
+
+	// Named connections without slaves serve reads and writes themselves
+	require.NoError(t, manager.AddConnection("logs", ConnectionConfig{Database: ":memory:"}))
+	assert.Same(t, manager.Connection("logs"), manager.ConnectionRead("logs"))
+	assert.NoError(t, manager.ConnectionWrite("logs").Exec("SELECT 1").Error)
+}
+
+// TestManager_Cluster_FailedSlave tests that reads skip a slave that failed to connect
+func TestManager_Cluster_FailedSlave(t *testing.T) {
+	registerUnavailableDriver(t, "test-cluster-unavailable")
+	logger := &mockSlowQueryLogger{}
+
+	dir := t.TempDir()
+	config := Config{
+		Driver:      "sqlite",
+		FilePath:    filepath.Join(dir, "primary.db"),
+		AutoRouting: true,
+		Connections: map[string]ConnectionConfig{
+			"analytics": {
+				FilePath: filepath.Join(dir, "analytics.db"),
+				Slaves: []ConnectionConfig{
+					{Driver: "test-cluster-unavailable"},
+				},
+			},
+		},
+	}
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	c := manager.cluster("analytics")
+	require.Len(t, c.replicas, 1)
+	assert.Equal(t, ReplicaFailed, c.replicas[0].state)
+	assert.Contains(t, logger.warnings, "No slaves available, using master for reads")
+
+	assert.Same(t, manager.Connection("analytics"), manager.ConnectionRead("analytics"))
+	var one int
+	assert.NoError(t, manager.Connection("analytics").Raw("SELECT 1").Scan(&one).Error)
+
+	// The failed slave is reported as unhealthy
+	assert.False(t, manager.HealthCheck()["analytics/slave_0"])
+	assert.Equal(t, HealthStatusUnhealthy, manager.DetailedHealthCheck()["analytics/slave_0"].Status)
+	assert.NotContains(t, manager.AllStats(), "analytics/slave_0")
+}
+
+// TestManager_Cluster_HealthAndStats tests that the slaves of named connections are reported
+func TestManager_Cluster_HealthAndStats(t *testing.T) {
+	manager := newClusterManager(t, nil)
+
+	health := manager.HealthCheck()
+	assert.True(t, health["analytics"])
+	assert.True(t, health["analytics/replica_a"])
+	assert.True(t, health["analytics/replica_b"])
+
+	detailed := manager.DetailedHealthCheck()
+	assert.Equal(t, HealthStatusHealthy, detailed["analytics/replica_a"].Status)
+	assert.Equal(t, HealthStatusHealthy, detailed["analytics/replica_b"].Status)
+
+	stats := manager.AllStats()
+	assert.Contains(t, stats, "analytics/replica_a")
+	assert.Contains(t, stats, "analytics/replica_b")
+}
+
+// TestManager_Cluster_HealthMonitor tests that the monitor ejects a failed slave of a named connection
+func TestManager_Cluster_HealthMonitor(t *testing.T) {
+	manager := newClusterManager(t, func(c *Config) {
+		c.HealthMonitor = HealthMonitorConfig{Enabled: true, Interval: 10 * time.Millisecond, Timeout: time.Second}
+	})
+	c := manager.cluster("analytics")
+
+	// Simulate a replica outage
+	sqlDB, err := c.replicas[0].db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	require.Eventually(t, func() bool {
+		return !c.replicaSnapshot()[0].routable()
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.True(t, c.replicaSnapshot()[1].routable())
+
+	for i := 0; i < 4; i++ {
+		source, err := findSource(context.Background(), manager.Connection("analytics"))
+		require.NoError(t, err)
+		assert.Equal(t, "replica_b", source)
+	}
+}
+
+// TestManager_Cluster_ReplicationLag tests that lagging slaves of a named connection are skipped
+func TestManager_Cluster_ReplicationLag(t *testing.T) {
+	manager := newClusterManager(t, func(c *Config) {
+		c.MaxReplicationLag = time.Second
+		c.ReplicationLag = func(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+			return 5 * time.Second, nil
+		}
+	})
+	manager.stopHealthMonitor() // check synchronously below
+	manager.checkSlaves()
+
+	for _, r := range manager.cluster("analytics").replicaSnapshot() {
+		assert.True(t, r.stale, r.name)
+		assert.Equal(t, 5*time.Second, r.lag)
+	}
+
+	source, err := findSource(context.Background(), manager.Connection("analytics"))
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", source)
+}
+
+// TestManager_Cluster_SlavesInherit tests that slaves inherit from their named connection
+func TestManager_Cluster_SlavesInherit(t *testing.T) {
+	var slaveConfigs []DSNBuilder
+	RegisterDriver("test-cluster-inherit", func(config DSNBuilder) (gorm.Dialector, error) {
+		slaveConfigs = append(slaveConfigs, config)
+		return openDialector(ConnectionConfig{Driver: "sqlite", Database: ":memory:"})
+	})
+
+	maxOpen := 7
+	config := Config{
+		Driver:   "sqlite",
+		Database: ":memory:",
+		Connections: map[string]ConnectionConfig{
+			"analytics": {
+				Driver:       "test-cluster-inherit",
+				Database:     "analytics",
+				Username:     "reporter",
+				MaxOpenConns: &maxOpen,
+				Slaves:       []ConnectionConfig{{Host: "replica"}},
+			},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	require.Len(t, slaveConfigs, 2)
+	slave := slaveConfigs[1]
+	assert.Equal(t, "replica", slave.GetHost())
+	assert.Equal(t, "analytics", slave.GetDatabase())
+	assert.Equal(t, "reporter", slave.GetUsername())
+
+	sqlDB, err := manager.cluster("analytics").replicas[0].db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+}
+
+// TestManager_Cluster_Lifecycle tests that removing, reloading and closing a named connection closes its slaves
+func TestManager_Cluster_Lifecycle(t *testing.T) {
+	manager := newClusterManager(t, nil)
+	slaveDB := func() *gorm.DB { return manager.cluster("analytics").replicas[0].db }
+
+	// Pool limits and strategy change on the open slaves
+	next := manager.settings()
+	next.Connections = map[string]ConnectionConfig{"analytics": next.Connections["analytics"]}
+	analytics := next.Connections["analytics"]
+	maxOpen := 3
+	analytics.Slaves = []ConnectionConfig{analytics.Slaves[0], analytics.Slaves[1]}
+	analytics.Slaves[0].MaxOpenConns = &maxOpen
+	analytics.SlaveStrategy = StrategyRandom
+	next.Connections["analytics"] = analytics
+
+	kept := slaveDB()
+	require.NoError(t, manager.Reload(next))
+	assert.Same(t, kept, slaveDB())
+	assert.Equal(t, 3, maxOpenConnections(t, kept))
+	assert.IsType(t, RandomSelector{}, manager.cluster("analytics").selector)
+
+	// A changed slave replaces the named connection
+	analytics.Slaves = analytics.Slaves[:1]
+	next.Connections = map[string]ConnectionConfig{"analytics": analytics}
+	require.NoError(t, manager.Reload(next))
+	assert.Len(t, manager.cluster("analytics").replicas, 1)
+	sqlDB, _ := kept.DB()
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+
+	// Removing the named connection closes its slaves
+	kept = slaveDB()
+	require.NoError(t, manager.RemoveConnection("analytics"))
+	assert.Nil(t, manager.cluster("analytics"))
+	sqlDB, _ = kept.DB()
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+
+	// Shutdown closes the slaves of named connections
+	require.NoError(t, manager.AddConnection("analytics", analytics))
+	kept = slaveDB()
+	require.NoError(t, manager.Close())
+	sqlDB, _ = kept.DB()
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+	assert.ErrorIs(t, manager.ConnectionRead("analytics").Exec("SELECT 1").Error, ErrManagerClosed)
+}
+
+// TestManager_Cluster_RemoveWaitsForReads tests that removing a named connection
+// waits for slave reads without blocking other connections
+func TestManager_Cluster_RemoveWaitsForReads(t *testing.T) {
+	manager := newClusterManager(t, nil)
+	c := manager.cluster("analytics")
+	slave, r := c.readSlave()
+	require.NotNil(t, slave)
+
+	removed := make(chan error, 1)
+	go func() { removed <- manager.RemoveConnection("analytics") }()
+
+	// The connection is detached right away and the manager stays usable
+	require.Eventually(t, func() bool { return manager.cluster("analytics") == nil }, time.Second, time.Millisecond)
+	_, err := manager.ConnectionE("analytics")
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	require.NoError(t, manager.AddConnection("reports", ConnectionConfig{FilePath: filepath.Join(t.TempDir(), "reports.db")}))
+
+	select {
+	case <-removed:
+		t.Fatal("RemoveConnection returned before the slave read finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.reads.Done()
+	require.NoError(t, <-removed)
+	for _, db := range []*gorm.DB{slave, c.master} {
+		sqlDB, _ := db.DB()
+		assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+	}
+}
+
+// TestConfigValidate_ClusterSlaves tests the validation of named connection slaves
+func TestConfigValidate_ClusterSlaves(t *testing.T) {
+	base := Config{Driver: "sqlite", Database: ":memory:"}
+
+	tests := []struct {
+		name string
+		conn ConnectionConfig
+		want string
+	}{
+		{"unknown strategy", ConnectionConfig{SlaveStrategy: "fastest", Slaves: []ConnectionConfig{{}}}, "fastest"},
+		{"unknown driver", ConnectionConfig{Slaves: []ConnectionConfig{{Driver: "nope"}}}, "connection analytics: slave 0: unsupported driver: nope"},
+		{"duplicate name", ConnectionConfig{Slaves: []ConnectionConfig{{Name: "a"}, {Name: "a"}}}, "connection analytics: slave 1: duplicate replica name: a"},
+		{"nested slaves", ConnectionConfig{Slaves: []ConnectionConfig{{Slaves: []ConnectionConfig{{}}}}}, "slaves are only supported on named connections"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base.WithConnection("analytics", tt.conn)
+			assert.ErrorContains(t, config.Validate(), tt.want)
+		})
+	}
+
+	config := base.WithReadWriteSplitting(ConnectionConfig{Slaves: []ConnectionConfig{{}}})
+	assert.ErrorContains(t, config.Validate(), "master: slaves are only supported on named connections")
+
+	config = base.WithConnection("analytics", ConnectionConfig{Slaves: []ConnectionConfig{{}, {}}})
+	assert.NoError(t, config.Validate())
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -17,6 +18,10 @@ type Config struct {
 	Username string
 	Password string
 
+	// Credentials, when set, supplies the username and password for every new
+	// physical connection instead of Username/Password (rotating tokens).
+	Credentials CredentialsProvider
+
 	// SQLite specific
 	FilePath string
 
@@ -31,7 +36,17 @@ type Config struct {
 	Timezone  string
 	ParseTime bool
 	SSLMode   string
-	Schema    string // PostgreSQL schema (default: public), MySQL: not used
+	Schema    string // PostgreSQL schema (default: public), SQL Server: table prefix, MySQL: not used
+
+	// SQL Server specific
+	Instance string // Named instance, resolved through the SQL Server Browser
+	Encrypt  string // disable, false, true, strict
+
+	// Extra driver parameters appended to the DSN
+	Params map[string]string
+
+	// Transport security (MySQL, PostgreSQL)
+	TLS TLSConfig
 
 	// Logging
 	LogLevel      string // silent, error, warn, info
@@ -43,6 +58,13 @@ type Config struct {
 	// Connection retry configuration
 	Retry RetryConfig
 
+	// Background health monitoring of slaves
+	HealthMonitor HealthMonitorConfig
+
+	// Lazy defers connecting until a connection is first used through DB(),
+	// Read() or Connection(name) instead of connecting in NewManager.
+	Lazy bool
+
 	// Auto migration
 	AutoMigrate bool
 	Models      []interface{}
@@ -57,15 +79,51 @@ type Config struct {
 	// Slaves (read) connections
 	Slaves []ConnectionConfig
 
-	// Load balancing strategy
-	SlaveStrategy string // round-robin, random, weighted
+	// Load balancing strategy: round-robin (default), random, weighted,
+	// least-in-use, ewma or smooth-weighted
+	SlaveStrategy string
+
+	// ReplicaSelector replaces the SlaveStrategy selector when set.
+	ReplicaSelector ReplicaSelector
+
+	// MaxReplicationLag skips slaves that are further behind the master; reads
+	// fall back to master when every slave is stale. Lag is polled by the
+	// background monitor every HealthMonitor.Interval. 0 disables lag checks.
+	MaxReplicationLag time.Duration
+
+	// StickyWindow is how long reads stay on master after a write in a context
+	// created with WithStickySession (default: 5s).
+	StickyWindow time.Duration
+
+	// ReplicationLag measures the lag of a slave (default: PostgresReplicationLag
+	// or MySQLReplicationLag depending on the slave driver).
+	ReplicationLag ReplicationLagFunc
 
 	// ========== Multi-Connection Support ==========
 	// Named connections for multiple databases
 	Connections map[string]ConnectionConfig
 
-	// Default connection name
+	// DefaultConnection is the named connection returned by DB(). Empty,
+	// "default" and "primary" select the primary connection unless a named
+	// connection has that name.
 	DefaultConnection string
+
+	// StrictConnections makes Connection return a connection that fails with
+	// ErrConnectionNotFound for an unknown name instead of falling back to the
+	// primary connection.
+	StrictConnections bool
+
+	// ========== Multi-Tenancy ==========
+	// Tenant pools opened by Manager.ForTenant; Reload does not change them
+	Tenants TenantConfig
+
+	// ========== Sharding ==========
+	// Shard groups by name; each shard is a named connection
+	Shards map[string]ShardGroup
+
+	// live holds the settings a Manager changes on open connections in
+	// Reload; set by NewManager.
+	live *liveSettings
 }
 
 // SlowQueryConfig holds configuration for slow query logging.
@@ -75,6 +133,17 @@ type SlowQueryConfig struct {
 	LogStack  bool          // Include stack trace in logs
 }
 
+// HealthMonitorConfig configures the background monitor that takes unhealthy
+// slaves, including the slaves of named connections, out of read routing and
+// puts them back once they recover.
+type HealthMonitorConfig struct {
+	Enabled           bool          // Enable the health monitor
+	Interval          time.Duration // Time between checks (default: 10s)
+	Timeout           time.Duration // Timeout of a single check (default: 5s)
+	FailureThreshold  int           // Consecutive failed checks before a slave is ejected (default: 1)
+	RecoveryThreshold int           // Consecutive successful checks before a slave is reinstated (default: 3)
+}
+
 // RetryConfig holds configuration for connection retry logic.
 type RetryConfig struct {
 	Enabled       bool          // Enable connection retry
@@ -82,9 +151,45 @@ type RetryConfig struct {
 	InitialDelay  time.Duration // Initial delay before first retry
 	MaxDelay      time.Duration // Maximum delay between retries
 	BackoffFactor float64       // Exponential backoff multiplier
+	Jitter        string        // Jitter mode: none (default), full, equal
+	Retryable     RetryableFunc // Reports whether an error is retried (default: IsRetryableError)
+}
+
+// Retry jitter modes. Jitter spreads the retries of many clients restarting at
+// once so they do not hit the database in lockstep.
+const (
+	JitterNone  = "none"  // Plain exponential backoff
+	JitterFull  = "full"  // Random delay between 0 and the backoff delay
+	JitterEqual = "equal" // Half the backoff delay plus a random delay up to the other half
+)
+
+// TLSConfig holds transport security settings for MySQL and PostgreSQL connections.
+// For MySQL the settings are registered as a named tls.Config with the driver;
+// for PostgreSQL they map to sslmode, sslrootcert, sslcert and sslkey.
+type TLSConfig struct {
+	Enabled    bool   // Enable TLS
+	CAFile     string // PEM CA bundle used to verify the server
+	CertFile   string // Client certificate for mutual TLS
+	KeyFile    string // Client private key for mutual TLS
+	ServerName string // Expected server name (default: Host, MySQL only)
+	VerifyMode string // verify-full (default), verify-ca, skip-verify
 }
 
-// ConnectionConfig holds configuration for a single database connection
+// ConnectionConfig holds configuration for a single database connection.
+//
+// Master, slave and named connections inherit unset settings from the main Config:
+//   - Pool settings (MaxOpenConns, MaxIdleConns, ConnMaxLifetime, ConnMaxIdleTime)
+//     are inherited when nil.
+//   - Driver is inherited when empty.
+//   - When the driver matches the main driver, Port, Database, Charset, Timezone,
+//     SSLMode, Schema, Instance, Encrypt and Params are inherited when empty, TLS
+//     when not enabled and ParseTime when false. Username, Password and
+//     Credentials are inherited together when none of them is set.
+//   - Host and FilePath identify the node and are never inherited.
+//   - LogLevel, SlowThreshold, SlowQuery and Retry always come from the main Config.
+//
+// The slaves of a named connection inherit from the resolved named connection
+// instead of the main Config, by the same rules.
 type ConnectionConfig struct {
 	Driver   string
 	Host     string
@@ -93,6 +198,9 @@ type ConnectionConfig struct {
 	Username string
 	Password string
 
+	// Rotating credentials (optional, overrides Password)
+	Credentials CredentialsProvider
+
 	// SQLite
 	FilePath string
 
@@ -100,9 +208,17 @@ type ConnectionConfig struct {
 	MaxOpenConns    *int
 	MaxIdleConns    *int
 	ConnMaxLifetime *time.Duration
+	ConnMaxIdleTime *time.Duration
+
+	// Replica registry (slaves only)
+	Name   string            // Replica name in stats, health checks, Slave and UseReplica (default: slave_<index>)
+	Weight int               // For weighted load balancing
+	Tags   map[string]string // Labels passed to the ReplicaSelector, such as a zone
 
-	// For weighted load balancing
-	Weight int
+	// Read/write splitting (named connections only): the connection is the
+	// master and reads are routed to Slaves like for the primary connection
+	Slaves        []ConnectionConfig
+	SlaveStrategy string // Slave selection strategy (default: round-robin)
 
 	// Options
 	Charset   string
@@ -110,6 +226,16 @@ type ConnectionConfig struct {
 	ParseTime bool
 	SSLMode   string
 	Schema    string // PostgreSQL schema (default: public)
+
+	// SQL Server
+	Instance string
+	Encrypt  string
+
+	// Extra driver parameters appended to the DSN
+	Params map[string]string
+
+	// Transport security (MySQL, PostgreSQL)
+	TLS TLSConfig
 }
 
 // DefaultConfig returns the default configuration
@@ -266,6 +392,35 @@ func (c Config) WithDefaultRetry() Config {
 	return c
 }
 
+// WithHealthMonitor enables the background slave health monitor with the given
+// check interval and default thresholds.
+func (c Config) WithHealthMonitor(interval time.Duration) Config {
+	c.HealthMonitor = HealthMonitorConfig{
+		Enabled:  true,
+		Interval: interval,
+	}
+	return c
+}
+
+// WithStickyWindow sets how long reads stay on master after a write in a sticky session.
+func (c Config) WithStickyWindow(window time.Duration) Config {
+	c.StickyWindow = window
+	return c
+}
+
+// WithLazy enables lazy connection mode: connections are opened on first use.
+func (c Config) WithLazy() Config {
+	c.Lazy = true
+	return c
+}
+
+// WithTLS enables TLS with the given settings.
+func (c Config) WithTLS(tls TLSConfig) Config {
+	tls.Enabled = true
+	c.TLS = tls
+	return c
+}
+
 // WithMaxConnections sets the connection pool limits
 func (c Config) WithMaxConnections(maxOpen, maxIdle int) Config {
 	c.MaxOpenConns = maxOpen
@@ -279,6 +434,10 @@ func (c Config) Validate() error {
 		return fmt.Errorf("database driver is required")
 	}
 
+	if !isDriverRegistered(c.Driver) {
+		return fmt.Errorf("unsupported driver: %s", c.Driver)
+	}
+
 	if c.Driver != "sqlite" && c.Host == "" {
 		return fmt.Errorf("database host is required for driver %s", c.Driver)
 	}
@@ -287,5 +446,147 @@ func (c Config) Validate() error {
 		return fmt.Errorf("database file path or database name is required for sqlite")
 	}
 
+	if c.Driver == "sqlserver" {
+		if err := validateSQLServer(c); err != nil {
+			return err
+		}
+	}
+
+	if err := validateTLS(c); err != nil {
+		return err
+	}
+
+	switch c.Retry.Jitter {
+	case "", JitterNone, JitterFull, JitterEqual:
+	default:
+		return fmt.Errorf("invalid retry jitter mode: %s (must be none, full or equal)", c.Retry.Jitter)
+	}
+
+	if _, err := newReplicaSelector(c.SlaveStrategy); err != nil {
+		return err
+	}
+
+	if c.MaxReplicationLag < 0 {
+		return fmt.Errorf("max replication lag must not be negative")
+	}
+
+	if c.ReadWriteSplitting {
+		if c.Master.Driver != "" && !isDriverRegistered(c.Master.Driver) {
+			return fmt.Errorf("master: unsupported driver: %s", c.Master.Driver)
+		}
+		if len(c.Master.Slaves) > 0 {
+			return fmt.Errorf("master: slaves are only supported on named connections")
+		}
+		if c.Master.Host != "" {
+			if err := validateNode(c.inherit(c.Master)); err != nil {
+				return fmt.Errorf("master: %w", err)
+			}
+		}
+		names := make(map[string]bool, len(c.Slaves))
+		for i, slave := range c.Slaves {
+			if slave.Driver != "" && !isDriverRegistered(slave.Driver) {
+				return fmt.Errorf("slave %d: unsupported driver: %s", i, slave.Driver)
+			}
+			if len(slave.Slaves) > 0 {
+				return fmt.Errorf("slave %d: slaves are only supported on named connections", i)
+			}
+			name := replicaName(slave, i)
+			if names[name] {
+				return fmt.Errorf("slave %d: duplicate replica name: %s", i, name)
+			}
+			names[name] = true
+			if err := validateNode(c.inherit(slave)); err != nil {
+				return fmt.Errorf("slave %d: %w", i, err)
+			}
+			if c.MaxReplicationLag > 0 && c.ReplicationLag == nil {
+				driver := c.inherit(slave).Driver
+				if defaultReplicationLag(driver) == nil {
+					return fmt.Errorf("slave %d: replication lag is not supported for driver %s; set ReplicationLag", i, driver)
+				}
+			}
+		}
+	}
+
+	if c.DefaultConnection != "" && c.defaultNamedConnection() == "" {
+		switch c.DefaultConnection {
+		case "default", "primary":
+		default:
+			return fmt.Errorf("default connection not found: %s", c.DefaultConnection)
+		}
+	}
+
+	for name, conn := range c.Connections {
+		if conn.Driver != "" && !isDriverRegistered(conn.Driver) {
+			return fmt.Errorf("connection %s: unsupported driver: %s", name, conn.Driver)
+		}
+		resolved := c.inherit(conn)
+		if err := validateNode(resolved); err != nil {
+			return fmt.Errorf("connection %s: %w", name, err)
+		}
+		if err := validateCluster(conn); err != nil {
+			return fmt.Errorf("connection %s: %w", name, err)
+		}
+		for i, slave := range conn.Slaves {
+			if err := validateNode(inheritConnection(resolved, slave)); err != nil {
+				return fmt.Errorf("connection %s: slave %d: %w", name, i, err)
+			}
+		}
+	}
+
+	if err := c.validateShards(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// validateNode checks the driver specific settings of a master, slave or named
+// connection resolved with the settings it inherits.
+func validateNode(node ConnectionConfig) error {
+	if node.Driver == "sqlserver" {
+		if err := validateSQLServer(node); err != nil {
+			return err
+		}
+	}
+	return validateTLS(node)
+}
+
+// validateSQLServer checks the fields SQL Server needs to build a DSN.
+func validateSQLServer(c DSNBuilder) error {
+	if c.GetHost() == "" {
+		return fmt.Errorf("database host is required for driver sqlserver")
+	}
+
+	if c.GetDatabase() == "" {
+		return fmt.Errorf("database name is required for driver sqlserver")
+	}
+
+	// A CredentialsProvider supplies the username when connecting
+	if c.GetUsername() == "" && c.GetCredentials() == nil {
+		return fmt.Errorf("database username is required for driver sqlserver")
+	}
+
+	switch c.GetEncrypt() {
+	case "", "disable", "false", "true", "strict":
+	default:
+		return fmt.Errorf("invalid sqlserver encrypt mode: %s", c.GetEncrypt())
+	}
+
+	// These are set from Database and Encrypt
+	for key := range c.GetParams() {
+		if strings.EqualFold(key, "database") || strings.EqualFold(key, "encrypt") {
+			return fmt.Errorf("sqlserver params cannot set %s; use the Database or Encrypt field", key)
+		}
+	}
+
+	return nil
+}
+
+// defaultNamedConnection returns the named connection selected by
+// DefaultConnection, or "" when DB() uses the primary connection.
+func (c Config) defaultNamedConnection() string {
+	if _, ok := c.Connections[c.DefaultConnection]; ok {
+		return c.DefaultConnection
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -183,3 +184,116 @@ func TestConnectionConfigPoolSettings(t *testing.T) {
 		t.Errorf("Expected ConnMaxLifetime 1h, got %v", *config.ConnMaxLifetime)
 	}
 }
+
+func TestConfigValidate_SQLServer(t *testing.T) {
+	config := DefaultConfig().
+		WithDriver("sqlserver").
+		WithHost("mssql.example.com").
+		WithPort(1433).
+		WithDatabase("reporting").
+		WithCredentials("sa", "secret")
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got: %v", err)
+	}
+
+	config.Database = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for missing sqlserver database")
+	}
+
+	config.Database = "reporting"
+	config.Encrypt = "maybe"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for invalid sqlserver encrypt mode")
+	}
+
+	// Params cannot replace the typed fields
+	config.Encrypt = ""
+	config.Params = map[string]string{"Encrypt": "disable"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for encrypt set in sqlserver params")
+	}
+	config.Params = map[string]string{"app name": "reports"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config with sqlserver params, got: %v", err)
+	}
+
+	// A credentials provider replaces the username
+	config.Encrypt = ""
+	config.Username, config.Password = "", ""
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for missing sqlserver username")
+	}
+	config = config.WithCredentialsProvider(StaticCredentials{Username: "sa", Password: "secret"})
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config with a credentials provider, got: %v", err)
+	}
+}
+
+func TestConfigValidate_SQLServerNamedConnection(t *testing.T) {
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithConnection("reporting", ConnectionConfig{
+			Driver:   "sqlserver",
+			Host:     "mssql.example.com",
+			Database: "reporting",
+		})
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected error for missing sqlserver username")
+	}
+
+	if !strings.Contains(err.Error(), "connection reporting") {
+		t.Errorf("Expected error to name the connection, got: %v", err)
+	}
+}
+
+func TestConfigValidate_SQLServerNodes(t *testing.T) {
+	config := DefaultConfig().
+		WithDriver("sqlserver").
+		WithHost("mssql.example.com").
+		WithDatabase("reporting").
+		WithCredentials("sa", "secret")
+	config.ReadWriteSplitting = true
+	config.Master = ConnectionConfig{Host: "mssql-master.example.com"}
+	config.Slaves = []ConnectionConfig{{Host: "mssql-replica.example.com"}}
+
+	// Nodes inherit the database and credentials of the main config
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got: %v", err)
+	}
+
+	config.Slaves = append(config.Slaves, ConnectionConfig{Host: "mssql-replica-2.example.com", Encrypt: "maybe"})
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "slave 1") {
+		t.Errorf("Expected error for the slave encrypt mode, got: %v", err)
+	}
+
+	config.Slaves = config.Slaves[:1]
+	config.Master.Encrypt = "maybe"
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "master") {
+		t.Errorf("Expected error for the master encrypt mode, got: %v", err)
+	}
+}
+
+func TestConfigValidate_DefaultConnection(t *testing.T) {
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithConnection("reporting", ConnectionConfig{Database: ":memory:"})
+
+	for _, name := range []string{"", "default", "primary", "reporting"} {
+		if err := config.WithDefaultConnection(name).Validate(); err != nil {
+			t.Errorf("Expected default connection %q to be valid, got: %v", name, err)
+		}
+	}
+
+	err := config.WithDefaultConnection("analytics").Validate()
+	if err == nil || !strings.Contains(err.Error(), "default connection not found: analytics") {
+		t.Errorf("Expected error for unknown default connection, got: %v", err)
+	}
+}
@@ -1,119 +1,260 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
-// connect creates a database connection from the main config.
-func connect(config Config, log Logger) (*gorm.DB, error) {
-	// Use retry logic if enabled
-	if config.Retry.Enabled {
-		// Convert Config to ConnectionConfig for retry
-		connConfig := ConnectionConfig{
-			Driver:    config.Driver,
-			Host:      config.Host,
-			Port:      config.Port,
-			Database:  config.Database,
-			Username:  config.Username,
-			Password:  config.Password,
-			FilePath:  config.FilePath,
-			Charset:   config.Charset,
-			Timezone:  config.Timezone,
-			ParseTime: config.ParseTime,
-			SSLMode:   config.SSLMode,
-			Schema:    config.Schema,
-		}
-		return connectWithRetry(connConfig, config.Retry, log)
+// connect creates the primary database connection from the main config.
+func connect(ctx context.Context, config Config, log Logger) (*gorm.DB, error) {
+	return openConnection(ctx, "primary", config.primaryConnection(), config, log)
+}
+
+// connectWithConfig creates a standalone connection from ConnectionConfig,
+// without settings inherited from a main config.
+func connectWithConfig(config ConnectionConfig, log Logger) (*gorm.DB, error) {
+	return openConnection(context.Background(), config.Name, config, Config{LogLevel: "silent"}, log)
+}
+
+// primaryConnection returns the main connection as a ConnectionConfig.
+func (c Config) primaryConnection() ConnectionConfig {
+	maxOpen, maxIdle := c.MaxOpenConns, c.MaxIdleConns
+	lifetime, idleTime := c.ConnMaxLifetime, c.ConnMaxIdleTime
+
+	return ConnectionConfig{
+		Driver:          c.Driver,
+		Host:            c.Host,
+		Port:            c.Port,
+		Database:        c.Database,
+		Username:        c.Username,
+		Password:        c.Password,
+		Credentials:     c.Credentials,
+		FilePath:        c.FilePath,
+		MaxOpenConns:    &maxOpen,
+		MaxIdleConns:    &maxIdle,
+		ConnMaxLifetime: &lifetime,
+		ConnMaxIdleTime: &idleTime,
+		Charset:         c.Charset,
+		Timezone:        c.Timezone,
+		ParseTime:       c.ParseTime,
+		SSLMode:         c.SSLMode,
+		Schema:          c.Schema,
+		Instance:        c.Instance,
+		Encrypt:         c.Encrypt,
+		Params:          c.Params,
+		TLS:             c.TLS,
 	}
+}
 
-	// No retry - use direct connection
-	// Build DSN
-	dsn := buildDSN(config)
+// inherit fills the unset fields of a master, slave or named connection from
+// the main config. See ConnectionConfig for the inheritance rules.
+func (c Config) inherit(conn ConnectionConfig) ConnectionConfig {
+	return inheritConnection(c.primaryConnection(), conn)
+}
 
-	// Select driver
-	var dialector gorm.Dialector
-	switch config.Driver {
-	case "mysql":
-		dialector = mysql.Open(dsn)
-	case "postgres":
-		dialector = postgres.Open(dsn)
-	case "sqlite":
-		dialector = sqlite.Open(config.FilePath)
-	default:
-		return nil, fmt.Errorf("unsupported driver: %s", config.Driver)
+// inheritConnection fills the unset fields of conn from the resolved
+// connection main.
+func inheritConnection(main, conn ConnectionConfig) ConnectionConfig {
+
+	// Pool settings are always inherited when unset
+	if conn.MaxOpenConns == nil {
+		conn.MaxOpenConns = main.MaxOpenConns
+	}
+	if conn.MaxIdleConns == nil {
+		conn.MaxIdleConns = main.MaxIdleConns
+	}
+	if conn.ConnMaxLifetime == nil {
+		conn.ConnMaxLifetime = main.ConnMaxLifetime
+	}
+	if conn.ConnMaxIdleTime == nil {
+		conn.ConnMaxIdleTime = main.ConnMaxIdleTime
 	}
 
-	// Configure GORM
-	gormConfig := &gorm.Config{
-		Logger: getLogger(config.LogLevel, config.SlowThreshold),
+	if conn.Driver == "" {
+		conn.Driver = main.Driver
 	}
 
-	// Open connection
-	db, err := gorm.Open(dialector, gormConfig)
+	// Connection options are driver specific and only inherited from the same driver
+	if conn.Driver != main.Driver {
+		return conn
+	}
+
+	if conn.Port == 0 {
+		conn.Port = main.Port
+	}
+	if conn.Database == "" {
+		conn.Database = main.Database
+	}
+	if conn.Username == "" && conn.Password == "" && conn.Credentials == nil {
+		conn.Username = main.Username
+		conn.Password = main.Password
+		conn.Credentials = main.Credentials
+	}
+	if conn.Charset == "" {
+		conn.Charset = main.Charset
+	}
+	if conn.Timezone == "" {
+		conn.Timezone = main.Timezone
+	}
+	conn.ParseTime = conn.ParseTime || main.ParseTime
+	if conn.SSLMode == "" {
+		conn.SSLMode = main.SSLMode
+	}
+	if conn.Schema == "" {
+		conn.Schema = main.Schema
+	}
+	if conn.Instance == "" {
+		conn.Instance = main.Instance
+	}
+	if conn.Encrypt == "" {
+		conn.Encrypt = main.Encrypt
+	}
+	if conn.Params == nil {
+		conn.Params = main.Params
+	}
+	if !conn.TLS.Enabled {
+		conn.TLS = main.TLS
+	}
+
+	return conn
+}
+
+// openConnection is the single path used to open the primary, master, slave and
+// named connections. config must already be resolved (see Config.inherit);
+// settings provides the shared logging, retry and slow query configuration.
+// ctx bounds the connection attempts and retry delays. name identifies the
+// connection in slow query logs.
+func openConnection(ctx context.Context, name string, config ConnectionConfig, settings Config, log Logger) (*gorm.DB, error) {
+	var (
+		db  *gorm.DB
+		err error
+	)
+	if settings.Retry.Enabled {
+		db, err = connectWithRetry(ctx, config, settings, log)
+	} else {
+		db, err = openConnectionOnce(ctx, config, settings, log)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Setup slow query logging if enabled; connections of a Manager always get
+	// the plugin so Reload can turn logging on
+	if settings.SlowQuery.Enabled || settings.live != nil {
+		plugin := NewSlowQueryPlugin(settings.SlowQuery, log)
+		plugin.name = name
+		plugin.live = settings.live
+		if err := db.Use(plugin); err != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+			return nil, fmt.Errorf("failed to register slow query plugin: %w", err)
+		}
+	}
+
 	return db, nil
 }
 
-// connectWithConfig creates a connection from ConnectionConfig.
-func connectWithConfig(config ConnectionConfig, log Logger) (*gorm.DB, error) {
-	// Build DSN
-	dsn := buildDSN(config)
-
+// openConnectionOnce makes a single connection attempt.
+func openConnectionOnce(ctx context.Context, config ConnectionConfig, settings Config, log Logger) (*gorm.DB, error) {
 	// Select driver
-	var dialector gorm.Dialector
-	switch config.Driver {
-	case "mysql":
-		dialector = mysql.Open(dsn)
-	case "postgres":
-		dialector = postgres.Open(dsn)
-	case "sqlite":
-		dialector = sqlite.Open(config.FilePath)
-	default:
-		return nil, fmt.Errorf("unsupported driver: %s", config.Driver)
+	dialector, err := openDialector(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// Configure GORM
+	// Configure GORM; the connection is verified below with ctx instead of
+	// GORM's automatic ping, which cannot be cancelled
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+		Logger:               settings.gormLogger(),
+		DisableAutomaticPing: true,
 	}
+	applySQLServerSchema(gormConfig, config)
 
 	// Open connection
 	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+		}
 		return nil, err
 	}
 
-	// Configure connection pool if specified
+	// Configure connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
 
-	if config.MaxOpenConns != nil {
-		sqlDB.SetMaxOpenConns(*config.MaxOpenConns)
+	configurePool(sqlDB, config)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
 	}
-	if config.MaxIdleConns != nil {
-		sqlDB.SetMaxIdleConns(*config.MaxIdleConns)
+
+	return db, nil
+}
+
+// configurePool applies the pool settings of a resolved connection config.
+func configurePool(sqlDB *sql.DB, config ConnectionConfig) {
+	// Special handling for SQLite :memory: databases
+	// They must use a single connection because each connection has its own database
+	if config.Driver == "sqlite" && (config.Database == ":memory:" || config.FilePath == ":memory:") {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+	} else {
+		if config.MaxOpenConns != nil {
+			sqlDB.SetMaxOpenConns(*config.MaxOpenConns)
+		}
+		if config.MaxIdleConns != nil {
+			sqlDB.SetMaxIdleConns(*config.MaxIdleConns)
+		}
 	}
 	if config.ConnMaxLifetime != nil {
 		sqlDB.SetConnMaxLifetime(*config.ConnMaxLifetime)
 	}
+	if config.ConnMaxIdleTime != nil {
+		sqlDB.SetConnMaxIdleTime(*config.ConnMaxIdleTime)
+	}
+}
 
-	return db, nil
+// applySQLServerSchema qualifies table names with the configured schema for SQL Server,
+// which has no connection-level search_path.
+func applySQLServerSchema(gormConfig *gorm.Config, config DSNBuilder) {
+	if config.GetDriver() == "sqlserver" && config.GetSchema() != "" {
+		gormConfig.NamingStrategy = schema.NamingStrategy{
+			TablePrefix: config.GetSchema() + ".",
+		}
+	}
+}
+
+// gormLogger returns the GORM logger for connections opened with these
+// settings. Connections of a Manager share a logger that follows Reload.
+func (c Config) gormLogger() logger.Interface {
+	if c.live != nil {
+		return &c.live.logger
+	}
+	return getLogger(c.LogLevel, c.SlowThreshold)
 }
 
 // getLogger returns a GORM logger based on log level.
 func getLogger(logLevel string, slowThreshold time.Duration) logger.Interface {
+	return newGORMLogger(log.New(os.Stdout, "\r\n", log.LstdFlags), logLevel, slowThreshold)
+}
+
+// newGORMLogger returns a GORM logger writing to writer.
+func newGORMLogger(writer logger.Writer, logLevel string, slowThreshold time.Duration) logger.Interface {
 	var level logger.LogLevel
 
 	switch logLevel {
@@ -129,5 +270,9 @@ func getLogger(logLevel string, slowThreshold time.Duration) logger.Interface {
 		level = logger.Warn
 	}
 
-	return logger.Default.LogMode(level)
+	return logger.New(writer, logger.Config{
+		SlowThreshold: slowThreshold,
+		LogLevel:      level,
+		Colorful:      true,
+	})
 }
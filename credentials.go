@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// CredentialsProvider supplies the username and password for new database connections.
+// It is consulted every time a new physical connection is opened, so rotated
+// credentials (e.g. short-lived IAM or Vault tokens) are picked up without
+// restarting the Manager. Connections that are already open keep working until
+// they are recycled; set ConnMaxLifetime below the credential lifetime.
+//
+// Providers are called from the connection pool and must be safe for concurrent
+// use. Expensive providers should cache credentials until shortly before expiry.
+type CredentialsProvider interface {
+	// Credentials returns the username and password to authenticate with.
+	// An empty username keeps the configured Username.
+	Credentials(ctx context.Context) (username, password string, err error)
+}
+
+// CredentialsFunc adapts an ordinary function to the CredentialsProvider interface.
+type CredentialsFunc func(ctx context.Context) (username, password string, err error)
+
+// Credentials calls f(ctx).
+func (f CredentialsFunc) Credentials(ctx context.Context) (string, string, error) {
+	return f(ctx)
+}
+
+// StaticCredentials is a CredentialsProvider that always returns the same credentials.
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+// Credentials returns the static username and password.
+func (s StaticCredentials) Credentials(ctx context.Context) (string, string, error) {
+	return s.Username, s.Password, nil
+}
+
+// WithCredentialsProvider sets a provider for rotating credentials.
+func (c Config) WithCredentialsProvider(provider CredentialsProvider) Config {
+	c.Credentials = provider
+	return c
+}
+
+// credentialsOverride replaces the username and password of a DSNBuilder.
+type credentialsOverride struct {
+	DSNBuilder
+	username string
+	password string
+}
+
+func (c credentialsOverride) GetUsername() string { return c.username }
+func (c credentialsOverride) GetPassword() string { return c.password }
+
+// credentialsConnector is a driver.Connector that builds a fresh DSN from the
+// credentials provider for every new connection.
+type credentialsConnector struct {
+	driver   driver.Driver
+	config   DSNBuilder
+	buildDSN func(DSNBuilder) string
+}
+
+// Connect opens a new connection with the current credentials.
+func (c *credentialsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	username, password, err := c.config.GetCredentials().Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database credentials: %w", err)
+	}
+	if username == "" {
+		username = c.config.GetUsername()
+	}
+
+	dsn := c.buildDSN(credentialsOverride{
+		DSNBuilder: c.config,
+		username:   username,
+		password:   password,
+	})
+
+	if driverCtx, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := driverCtx.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+
+	return c.driver.Open(dsn)
+}
+
+// Driver returns the underlying driver.
+func (c *credentialsConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openCredentialsPool opens a connection pool that asks the configured
+// CredentialsProvider for credentials on every new connection. It returns nil
+// when no provider is configured. driverName is the database/sql driver name.
+func openCredentialsPool(config DSNBuilder, driverName string, buildDSN func(DSNBuilder) string) (*sql.DB, error) {
+	if config.GetCredentials() == nil {
+		return nil, nil
+	}
+
+	// sql.Open only resolves the registered driver; it does not connect
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	sqlDriver := db.Driver()
+	_ = db.Close()
+
+	return sql.OpenDB(&credentialsConnector{
+		driver:   sqlDriver,
+		config:   config,
+		buildDSN: buildDSN,
+	}), nil
+}
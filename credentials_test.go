@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDriver is a database/sql driver that records the DSNs it is asked to open
+type recordingDriver struct {
+	mu   sync.Mutex
+	dsns []string
+}
+
+func (d *recordingDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dsns = append(d.dsns, dsn)
+	return nil, errors.New("recording driver does not connect")
+}
+
+var testRecordingDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("dgtest-recording", testRecordingDriver)
+}
+
+// TestCredentialsConnector_RotatesPerConnection tests that every new connection uses fresh credentials
+func TestCredentialsConnector_RotatesPerConnection(t *testing.T) {
+	var calls int32
+	provider := CredentialsFunc(func(ctx context.Context) (string, string, error) {
+		n := atomic.AddInt32(&calls, 1)
+		return "", fmt.Sprintf("token-%d", n), nil
+	})
+
+	config := ConnectionConfig{
+		Driver:      "mysql",
+		Host:        "db.internal",
+		Port:        3306,
+		Database:    "app",
+		Username:    "app",
+		Credentials: provider,
+	}
+
+	pool, err := openCredentialsPool(config, "dgtest-recording", buildMySQLDSN)
+	require.NoError(t, err)
+	require.NotNil(t, pool)
+	defer pool.Close()
+
+	_ = pool.Ping()
+	_ = pool.Ping()
+
+	testRecordingDriver.mu.Lock()
+	dsns := append([]string(nil), testRecordingDriver.dsns...)
+	testRecordingDriver.mu.Unlock()
+
+	require.GreaterOrEqual(t, len(dsns), 2)
+	assert.Contains(t, dsns[0], "app:token-1@tcp(db.internal:3306)/app")
+	assert.Contains(t, dsns[1], "app:token-2@tcp(db.internal:3306)/app")
+}
+
+// TestCredentialsConnector_ProviderError tests that provider errors fail the connection
+func TestCredentialsConnector_ProviderError(t *testing.T) {
+	config := ConnectionConfig{
+		Driver: "mysql",
+		Host:   "db.internal",
+		Credentials: CredentialsFunc(func(ctx context.Context) (string, string, error) {
+			return "", "", errors.New("vault unavailable")
+		}),
+	}
+
+	pool, err := openCredentialsPool(config, "dgtest-recording", buildMySQLDSN)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	err = pool.Ping()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vault unavailable")
+}
+
+// TestOpenCredentialsPool_NoProvider tests that no pool is created without a provider
+func TestOpenCredentialsPool_NoProvider(t *testing.T) {
+	pool, err := openCredentialsPool(ConnectionConfig{Driver: "mysql"}, "mysql", buildMySQLDSN)
+	assert.NoError(t, err)
+	assert.Nil(t, pool)
+}
+
+// TestManager_CredentialsProvider tests that the provider is consulted for new connections
+func TestManager_CredentialsProvider(t *testing.T) {
+	var calls int32
+	provider := CredentialsFunc(func(ctx context.Context) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "user", "rotating", nil
+	})
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithCredentialsProvider(provider)
+	config.FilePath = filepath.Join(t.TempDir(), "credentials.db")
+	config.MaxIdleConns = 0
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	before := atomic.LoadInt32(&calls)
+	require.Greater(t, before, int32(0))
+
+	// Without idle connections every query needs a new physical connection
+	require.NoError(t, manager.DB().Exec("SELECT 1").Error)
+	require.NoError(t, manager.DB().Exec("SELECT 1").Error)
+
+	assert.Greater(t, atomic.LoadInt32(&calls), before)
+}
+
+// TestStaticCredentials tests the static provider
+func TestStaticCredentials(t *testing.T) {
+	username, password, err := StaticCredentials{Username: "u", Password: "p"}.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "u", username)
+	assert.Equal(t, "p", password)
+}
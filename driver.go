@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// DriverFactory creates a GORM dialector from a connection configuration.
+type DriverFactory func(DSNBuilder) (gorm.Dialector, error)
+
+var (
+	drivers   = make(map[string]DriverFactory)
+	driversMu sync.RWMutex
+)
+
+func init() {
+	RegisterDriver("mysql", func(config DSNBuilder) (gorm.Dialector, error) {
+		if err := registerMySQLTLS(config); err != nil {
+			return nil, err
+		}
+		pool, err := openCredentialsPool(config, "mysql", buildMySQLDSN)
+		if err != nil || pool == nil {
+			return mysql.Open(buildMySQLDSN(config)), err
+		}
+		return mysql.New(mysql.Config{Conn: pool}), nil
+	})
+	RegisterDriver("postgres", func(config DSNBuilder) (gorm.Dialector, error) {
+		pool, err := openCredentialsPool(config, "pgx", buildPostgresDSN)
+		if err != nil || pool == nil {
+			return postgres.Open(buildPostgresDSN(config)), err
+		}
+		return postgres.New(postgres.Config{Conn: pool}), nil
+	})
+	RegisterDriver("sqlite", func(config DSNBuilder) (gorm.Dialector, error) {
+		pool, err := openCredentialsPool(config, sqlite.DriverName, buildSQLiteDSN)
+		if err != nil || pool == nil {
+			return sqlite.Open(buildSQLiteDSN(config)), err
+		}
+		return sqlite.New(sqlite.Config{Conn: pool}), nil
+	})
+	RegisterDriver("sqlserver", func(config DSNBuilder) (gorm.Dialector, error) {
+		pool, err := openCredentialsPool(config, "sqlserver", buildSQLServerDSN)
+		if err != nil || pool == nil {
+			return sqlserver.Open(buildSQLServerDSN(config)), err
+		}
+		return sqlserver.New(sqlserver.Config{Conn: pool}), nil
+	})
+}
+
+// RegisterDriver makes a database driver available under the given name.
+// Registering a name that already exists replaces its factory, which allows
+// swapping a built-in driver (e.g. a CGO-free SQLite build).
+//
+// Example:
+//
+//	database.RegisterDriver("cockroach", func(c database.DSNBuilder) (gorm.Dialector, error) {
+//	    return postgres.Open(fmt.Sprintf("postgresql://%s@%s:%d/%s",
+//	        c.GetUsername(), c.GetHost(), c.GetPort(), c.GetDatabase())), nil
+//	})
+func RegisterDriver(name string, factory DriverFactory) {
+	if name == "" {
+		panic("database: RegisterDriver name is empty")
+	}
+	if factory == nil {
+		panic("database: RegisterDriver factory is nil for driver " + name)
+	}
+
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[name] = factory
+}
+
+// Drivers returns a sorted list of the registered driver names.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isDriverRegistered reports whether a driver with the given name is registered.
+func isDriverRegistered(name string) bool {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	_, exists := drivers[name]
+	return exists
+}
+
+// openDialector creates the dialector for a configuration using the registered driver factory.
+func openDialector(config DSNBuilder) (gorm.Dialector, error) {
+	driversMu.RLock()
+	factory, exists := drivers[config.GetDriver()]
+	driversMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unsupported driver: %s", config.GetDriver())
+	}
+
+	dialector, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s dialector: %w", config.GetDriver(), err)
+	}
+
+	return dialector, nil
+}
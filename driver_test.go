@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestDrivers_BuiltIn tests that the built-in drivers register themselves
+func TestDrivers_BuiltIn(t *testing.T) {
+	drivers := Drivers()
+
+	for _, name := range []string{"mysql", "postgres", "sqlite", "sqlserver"} {
+		assert.Contains(t, drivers, name, "%s should be registered", name)
+	}
+}
+
+// TestRegisterDriver_CustomDriver tests connecting through a registered driver
+func TestRegisterDriver_CustomDriver(t *testing.T) {
+	RegisterDriver("test-sqlite", func(config DSNBuilder) (gorm.Dialector, error) {
+		return sqlite.Open(config.GetFilePath()), nil
+	})
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithConnection("custom", ConnectionConfig{
+			Driver:   "test-sqlite",
+			FilePath: ":memory:",
+		})
+
+	require.NoError(t, config.Validate())
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.True(t, manager.HasConnection("custom"))
+	assert.NoError(t, manager.ping(manager.Connection("custom")))
+}
+
+// TestRegisterDriver_FactoryError tests that factory errors are returned by connect
+func TestRegisterDriver_FactoryError(t *testing.T) {
+	RegisterDriver("test-broken", func(config DSNBuilder) (gorm.Dialector, error) {
+		return nil, errors.New("missing credentials")
+	})
+
+	_, err := connectWithConfig(ConnectionConfig{Driver: "test-broken"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing credentials")
+}
+
+// TestRegisterDriver_InvalidArguments tests that invalid registrations panic
+func TestRegisterDriver_InvalidArguments(t *testing.T) {
+	assert.Panics(t, func() { RegisterDriver("", func(DSNBuilder) (gorm.Dialector, error) { return nil, nil }) })
+	assert.Panics(t, func() { RegisterDriver("test-nil", nil) })
+}
+
+// TestConfigValidate_UnregisteredDriver tests that unknown drivers are rejected
+func TestConfigValidate_UnregisteredDriver(t *testing.T) {
+	config := DefaultConfig().WithDriver("cockroach")
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unsupported driver: cockroach"))
+
+	config = DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithConnection("tidb", ConnectionConfig{Driver: "tidb", Host: "tidb.local"})
+
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection tidb")
+}
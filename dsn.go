@@ -1,6 +1,11 @@
 package database
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
 
 // DSNBuilder defines the interface for building Data Source Names (DSNs).
 // This allows reusing DSN building logic for both Config and ConnectionConfig.
@@ -17,35 +22,50 @@ type DSNBuilder interface {
 	GetTimezone() string
 	GetSchema() string
 	GetFilePath() string
+	GetInstance() string
+	GetEncrypt() string
+	GetParams() map[string]string
+	GetCredentials() CredentialsProvider
+	GetTLS() TLSConfig
 }
 
 // Config implementation of DSNBuilder
-func (c Config) GetDriver() string   { return c.Driver }
-func (c Config) GetHost() string     { return c.Host }
-func (c Config) GetPort() int        { return c.Port }
-func (c Config) GetDatabase() string { return c.Database }
-func (c Config) GetUsername() string { return c.Username }
-func (c Config) GetPassword() string { return c.Password }
-func (c Config) GetCharset() string  { return c.Charset }
-func (c Config) GetParseTime() bool  { return c.ParseTime }
-func (c Config) GetSSLMode() string  { return c.SSLMode }
-func (c Config) GetTimezone() string { return c.Timezone }
-func (c Config) GetSchema() string   { return c.Schema }
-func (c Config) GetFilePath() string { return c.FilePath }
+func (c Config) GetDriver() string                   { return c.Driver }
+func (c Config) GetHost() string                     { return c.Host }
+func (c Config) GetPort() int                        { return c.Port }
+func (c Config) GetDatabase() string                 { return c.Database }
+func (c Config) GetUsername() string                 { return c.Username }
+func (c Config) GetPassword() string                 { return c.Password }
+func (c Config) GetCharset() string                  { return c.Charset }
+func (c Config) GetParseTime() bool                  { return c.ParseTime }
+func (c Config) GetSSLMode() string                  { return c.SSLMode }
+func (c Config) GetTimezone() string                 { return c.Timezone }
+func (c Config) GetSchema() string                   { return c.Schema }
+func (c Config) GetFilePath() string                 { return c.FilePath }
+func (c Config) GetInstance() string                 { return c.Instance }
+func (c Config) GetEncrypt() string                  { return c.Encrypt }
+func (c Config) GetParams() map[string]string        { return c.Params }
+func (c Config) GetCredentials() CredentialsProvider { return c.Credentials }
+func (c Config) GetTLS() TLSConfig                   { return c.TLS }
 
 // ConnectionConfig implementation of DSNBuilder
-func (c ConnectionConfig) GetDriver() string   { return c.Driver }
-func (c ConnectionConfig) GetHost() string     { return c.Host }
-func (c ConnectionConfig) GetPort() int        { return c.Port }
-func (c ConnectionConfig) GetDatabase() string { return c.Database }
-func (c ConnectionConfig) GetUsername() string { return c.Username }
-func (c ConnectionConfig) GetPassword() string { return c.Password }
-func (c ConnectionConfig) GetCharset() string  { return c.Charset }
-func (c ConnectionConfig) GetParseTime() bool  { return c.ParseTime }
-func (c ConnectionConfig) GetSSLMode() string  { return c.SSLMode }
-func (c ConnectionConfig) GetTimezone() string { return c.Timezone }
-func (c ConnectionConfig) GetSchema() string   { return c.Schema }
-func (c ConnectionConfig) GetFilePath() string { return c.FilePath }
+func (c ConnectionConfig) GetDriver() string                   { return c.Driver }
+func (c ConnectionConfig) GetHost() string                     { return c.Host }
+func (c ConnectionConfig) GetPort() int                        { return c.Port }
+func (c ConnectionConfig) GetDatabase() string                 { return c.Database }
+func (c ConnectionConfig) GetUsername() string                 { return c.Username }
+func (c ConnectionConfig) GetPassword() string                 { return c.Password }
+func (c ConnectionConfig) GetCharset() string                  { return c.Charset }
+func (c ConnectionConfig) GetParseTime() bool                  { return c.ParseTime }
+func (c ConnectionConfig) GetSSLMode() string                  { return c.SSLMode }
+func (c ConnectionConfig) GetTimezone() string                 { return c.Timezone }
+func (c ConnectionConfig) GetSchema() string                   { return c.Schema }
+func (c ConnectionConfig) GetFilePath() string                 { return c.FilePath }
+func (c ConnectionConfig) GetInstance() string                 { return c.Instance }
+func (c ConnectionConfig) GetEncrypt() string                  { return c.Encrypt }
+func (c ConnectionConfig) GetParams() map[string]string        { return c.Params }
+func (c ConnectionConfig) GetCredentials() CredentialsProvider { return c.Credentials }
+func (c ConnectionConfig) GetTLS() TLSConfig                   { return c.TLS }
 
 // buildDSN builds a DSN string from any configuration implementing DSNBuilder.
 func buildDSN(config DSNBuilder) string {
@@ -55,7 +75,9 @@ func buildDSN(config DSNBuilder) string {
 	case "postgres":
 		return buildPostgresDSN(config)
 	case "sqlite":
-		return config.GetFilePath()
+		return buildSQLiteDSN(config)
+	case "sqlserver":
+		return buildSQLServerDSN(config)
 	default:
 		return ""
 	}
@@ -73,7 +95,15 @@ func buildMySQLDSN(config DSNBuilder) string {
 		parseTime = "False"
 	}
 
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%s&loc=Local",
+	// Timezone is not used for MySQL so configs shared with PostgreSQL keep
+	// loc=Local; set loc in Params, as a URL does, to change it
+	params := config.GetParams()
+	loc := params["loc"]
+	if loc == "" {
+		loc = "Local"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%s&loc=%s",
 		config.GetUsername(),
 		config.GetPassword(),
 		config.GetHost(),
@@ -81,7 +111,23 @@ func buildMySQLDSN(config DSNBuilder) string {
 		config.GetDatabase(),
 		charset,
 		parseTime,
+		url.QueryEscape(loc),
 	)
+
+	// Use the TLS settings registered by registerMySQLTLS
+	if config.GetTLS().Enabled {
+		dsn += "&tls=" + mysqlTLSConfigName(config)
+	}
+
+	// Add extra driver parameters
+	for _, key := range sortedParamKeys(params) {
+		if key == "loc" {
+			continue
+		}
+		dsn += fmt.Sprintf("&%s=%s", url.QueryEscape(key), url.QueryEscape(params[key]))
+	}
+
+	return dsn
 }
 
 // buildPostgresDSN builds PostgreSQL DSN.
@@ -91,6 +137,16 @@ func buildPostgresDSN(config DSNBuilder) string {
 		sslMode = "disable"
 	}
 
+	tlsConfig := config.GetTLS()
+	if tlsConfig.Enabled {
+		switch tlsVerifyMode(tlsConfig) {
+		case TLSSkipVerify:
+			sslMode = "require"
+		default:
+			sslMode = tlsVerifyMode(tlsConfig)
+		}
+	}
+
 	timezone := config.GetTimezone()
 	if timezone == "" {
 		timezone = "UTC"
@@ -98,9 +154,9 @@ func buildPostgresDSN(config DSNBuilder) string {
 
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
 		config.GetHost(),
-		config.GetUsername(),
-		config.GetPassword(),
-		config.GetDatabase(),
+		quotePostgresValue(config.GetUsername()),
+		quotePostgresValue(config.GetPassword()),
+		quotePostgresValue(config.GetDatabase()),
 		config.GetPort(),
 		sslMode,
 		timezone,
@@ -108,8 +164,92 @@ func buildPostgresDSN(config DSNBuilder) string {
 
 	// Add schema (search_path) if specified
 	if config.GetSchema() != "" {
-		dsn += fmt.Sprintf(" search_path=%s", config.GetSchema())
+		dsn += fmt.Sprintf(" search_path=%s", quotePostgresValue(config.GetSchema()))
+	}
+
+	// Add certificates if TLS is enabled
+	if tlsConfig.Enabled {
+		if tlsConfig.CAFile != "" {
+			dsn += fmt.Sprintf(" sslrootcert=%s", quotePostgresValue(tlsConfig.CAFile))
+		}
+		if tlsConfig.CertFile != "" {
+			dsn += fmt.Sprintf(" sslcert=%s sslkey=%s",
+				quotePostgresValue(tlsConfig.CertFile),
+				quotePostgresValue(tlsConfig.KeyFile))
+		}
+	}
+
+	// Add extra driver parameters
+	for _, key := range sortedParamKeys(config.GetParams()) {
+		dsn += fmt.Sprintf(" %s=%s", key, quotePostgresValue(config.GetParams()[key]))
 	}
 
 	return dsn
 }
+
+// buildSQLiteDSN builds SQLite DSN.
+func buildSQLiteDSN(config DSNBuilder) string {
+	params := config.GetParams()
+	if len(params) == 0 {
+		return config.GetFilePath()
+	}
+
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+
+	return config.GetFilePath() + "?" + query.Encode()
+}
+
+// buildSQLServerDSN builds SQL Server DSN.
+// A named instance is addressed as host/instance and its port is resolved
+// through the SQL Server Browser, so the port is only used without an instance.
+// SQL Server has no search_path equivalent; the schema is applied as a table
+// prefix when the connection is opened.
+func buildSQLServerDSN(config DSNBuilder) string {
+	dsn := &url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(config.GetUsername(), config.GetPassword()),
+		Host:   config.GetHost(),
+	}
+
+	if instance := config.GetInstance(); instance != "" {
+		dsn.Path = instance
+	} else if config.GetPort() != 0 {
+		dsn.Host = fmt.Sprintf("%s:%d", config.GetHost(), config.GetPort())
+	}
+
+	// Params are applied first so they cannot override the typed fields
+	query := url.Values{}
+	for key, value := range config.GetParams() {
+		query.Set(key, value)
+	}
+	query.Set("database", config.GetDatabase())
+	if encrypt := config.GetEncrypt(); encrypt != "" {
+		query.Set("encrypt", encrypt)
+	}
+	dsn.RawQuery = query.Encode()
+
+	return dsn.String()
+}
+
+// sortedParamKeys returns the keys of params in a stable order so DSNs are deterministic.
+func sortedParamKeys(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quotePostgresValue quotes a value for a key/value PostgreSQL DSN when needed.
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
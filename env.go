@@ -0,0 +1,423 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// connectionEnvFields lists the variable suffixes understood for a ConnectionConfig.
+// It is used to split named connection variables such as DB_CONN_BILLING_EU_HOST
+// into the connection name (billing_eu) and the field (HOST).
+var connectionEnvFields = []string{
+	"DRIVER", "HOST", "PORT", "DATABASE", "USERNAME", "PASSWORD", "FILE_PATH",
+	"MAX_OPEN_CONNS", "MAX_IDLE_CONNS", "CONN_MAX_LIFETIME", "CONN_MAX_IDLE_TIME", "NAME", "WEIGHT", "TAGS",
+	"CHARSET", "TIMEZONE", "PARSE_TIME", "SSL_MODE", "SCHEMA", "INSTANCE", "ENCRYPT", "PARAMS",
+	"TLS_ENABLED", "TLS_CA_FILE", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_SERVER_NAME", "TLS_VERIFY_MODE",
+	"SLAVE_STRATEGY",
+}
+
+// ConfigFromEnv loads a Config from environment variables starting with prefix.
+// Loading starts from DefaultConfig, so unset variables keep their defaults.
+//
+// With prefix "DB" the main connection is read from DB_DRIVER, DB_HOST, DB_PORT,
+// DB_DATABASE, DB_USERNAME, DB_PASSWORD, DB_FILE_PATH, DB_CHARSET, DB_TIMEZONE,
+// DB_PARSE_TIME, DB_SSL_MODE, DB_SCHEMA, DB_INSTANCE and DB_ENCRYPT. Pool, logging,
+// retry and routing settings use the same naming (DB_MAX_OPEN_CONNS,
+// DB_CONN_MAX_LIFETIME, DB_LOG_LEVEL, DB_SLOW_QUERY_THRESHOLD, DB_RETRY_MAX_ATTEMPTS,
+// DB_READ_WRITE_SPLITTING, DB_SLAVE_STRATEGY, ...). TLS settings are read from
+// DB_TLS_ENABLED, DB_TLS_CA_FILE, DB_TLS_CERT_FILE, DB_TLS_KEY_FILE,
+// DB_TLS_SERVER_NAME and DB_TLS_VERIFY_MODE.
+//
+// The master is read from DB_MASTER_*, slaves from indexed variables starting at
+// zero (DB_SLAVE_0_HOST, DB_SLAVE_1_HOST, ...) and named connections from
+// DB_CONN_<NAME>_* where the name is lower-cased (DB_CONN_ANALYTICS_HOST defines
+// the "analytics" connection). A named connection reads its slaves and strategy
+// the same way as the main connection, from DB_CONN_<NAME>_SLAVE_<INDEX>_* and
+// DB_CONN_<NAME>_SLAVE_STRATEGY. DB_DEFAULT_CONNECTION and DB_STRICT_CONNECTIONS
+// set DefaultConnection and StrictConnections.
+//
+// Extra driver parameters are given in query string form, e.g.
+// DB_PARAMS="tls=custom&timeout=5s".
+//
+// Every variable can also be given as <NAME>_FILE pointing to a file holding the
+// value, e.g. DB_PASSWORD_FILE=/run/secrets/db_password. A directly set variable
+// takes precedence over its _FILE form.
+//
+// The tenant pool limits are read from DB_TENANTS_MAX_POOLS,
+// DB_TENANTS_IDLE_TIMEOUT and DB_TENANTS_MAX_OPEN_CONNS.
+//
+// Models, Tenants.Resolver, ReplicaSelector and other function or interface
+// settings cannot be expressed as environment variables and are left empty.
+// Conversion errors for all variables are collected and returned together.
+func ConfigFromEnv(prefix string) (Config, error) {
+	r := &envReader{prefix: envPrefix(prefix)}
+	config := DefaultConfig()
+
+	// Main connection
+	r.string("DRIVER", &config.Driver)
+	r.string("HOST", &config.Host)
+	r.int("PORT", &config.Port)
+	r.string("DATABASE", &config.Database)
+	r.string("USERNAME", &config.Username)
+	r.string("PASSWORD", &config.Password)
+	r.string("FILE_PATH", &config.FilePath)
+
+	// Connection pool
+	r.int("MAX_OPEN_CONNS", &config.MaxOpenConns)
+	r.int("MAX_IDLE_CONNS", &config.MaxIdleConns)
+	r.duration("CONN_MAX_LIFETIME", &config.ConnMaxLifetime)
+	r.duration("CONN_MAX_IDLE_TIME", &config.ConnMaxIdleTime)
+
+	// Options
+	r.string("CHARSET", &config.Charset)
+	r.string("TIMEZONE", &config.Timezone)
+	r.bool("PARSE_TIME", &config.ParseTime)
+	r.string("SSL_MODE", &config.SSLMode)
+	r.string("SCHEMA", &config.Schema)
+	r.string("INSTANCE", &config.Instance)
+	r.string("ENCRYPT", &config.Encrypt)
+	r.params("PARAMS", &config.Params)
+	r.tls("TLS_", &config.TLS)
+
+	// Logging
+	r.string("LOG_LEVEL", &config.LogLevel)
+	r.duration("SLOW_THRESHOLD", &config.SlowThreshold)
+
+	// Slow query logging
+	r.bool("SLOW_QUERY_ENABLED", &config.SlowQuery.Enabled)
+	r.duration("SLOW_QUERY_THRESHOLD", &config.SlowQuery.Threshold)
+	r.bool("SLOW_QUERY_LOG_STACK", &config.SlowQuery.LogStack)
+
+	// Connection retry
+	r.bool("RETRY_ENABLED", &config.Retry.Enabled)
+	r.int("RETRY_MAX_ATTEMPTS", &config.Retry.MaxAttempts)
+	r.duration("RETRY_INITIAL_DELAY", &config.Retry.InitialDelay)
+	r.duration("RETRY_MAX_DELAY", &config.Retry.MaxDelay)
+	r.float("RETRY_BACKOFF_FACTOR", &config.Retry.BackoffFactor)
+	r.string("RETRY_JITTER", &config.Retry.Jitter)
+
+	// Slave health monitor
+	r.bool("HEALTH_MONITOR_ENABLED", &config.HealthMonitor.Enabled)
+	r.duration("HEALTH_MONITOR_INTERVAL", &config.HealthMonitor.Interval)
+	r.duration("HEALTH_MONITOR_TIMEOUT", &config.HealthMonitor.Timeout)
+	r.int("HEALTH_MONITOR_FAILURE_THRESHOLD", &config.HealthMonitor.FailureThreshold)
+	r.int("HEALTH_MONITOR_RECOVERY_THRESHOLD", &config.HealthMonitor.RecoveryThreshold)
+
+	// Lazy connection mode
+	r.bool("LAZY", &config.Lazy)
+
+	// Auto migration
+	r.bool("AUTO_MIGRATE", &config.AutoMigrate)
+
+	// Read/write splitting
+	r.bool("READ_WRITE_SPLITTING", &config.ReadWriteSplitting)
+	r.bool("AUTO_ROUTING", &config.AutoRouting)
+	r.string("SLAVE_STRATEGY", &config.SlaveStrategy)
+	r.duration("MAX_REPLICATION_LAG", &config.MaxReplicationLag)
+	r.duration("STICKY_WINDOW", &config.StickyWindow)
+	r.connection("MASTER_", &config.Master)
+
+	config.Slaves = r.slaves("")
+
+	// Named connections
+	r.string("DEFAULT_CONNECTION", &config.DefaultConnection)
+	r.bool("STRICT_CONNECTIONS", &config.StrictConnections)
+	for _, name := range envConnectionNames(r.prefix + "CONN_") {
+		var conn ConnectionConfig
+		r.connection("CONN_"+name+"_", &conn)
+		r.string("CONN_"+name+"_SLAVE_STRATEGY", &conn.SlaveStrategy)
+		conn.Slaves = r.slaves("CONN_" + name + "_")
+		config.Connections[strings.ToLower(name)] = conn
+	}
+
+	// Tenant pools; the resolver is set in code
+	r.int("TENANTS_MAX_POOLS", &config.Tenants.MaxPools)
+	r.duration("TENANTS_IDLE_TIMEOUT", &config.Tenants.IdleTimeout)
+	r.int("TENANTS_MAX_OPEN_CONNS", &config.Tenants.MaxOpenConns)
+
+	if len(r.errs) > 0 {
+		return config, fmt.Errorf("invalid database environment: %w", errors.Join(r.errs...))
+	}
+
+	return config, nil
+}
+
+// envPrefix normalizes prefix so that it can be joined directly with a variable name.
+func envPrefix(prefix string) string {
+	prefix = strings.ToUpper(prefix)
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix
+}
+
+// envSlaveIndexes returns the sorted slave indexes present in the environment.
+// Indexes must be contiguous and start at zero.
+func envSlaveIndexes(prefix string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, key := range envKeys(prefix) {
+		rest := strings.TrimPrefix(key, prefix)
+		end := strings.Index(rest, "_")
+		if end <= 0 {
+			continue
+		}
+		index, err := strconv.Atoi(rest[:end])
+		if err != nil || index < 0 {
+			continue
+		}
+		seen[index] = true
+	}
+
+	indexes := make([]int, 0, len(seen))
+	for index := range seen {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for i, index := range indexes {
+		if i != index {
+			return nil, fmt.Errorf("%s%d_*: slave indexes must be contiguous and start at 0", prefix, i)
+		}
+	}
+
+	return indexes, nil
+}
+
+// envConnectionNames returns the sorted, upper-case connection names present in the environment.
+func envConnectionNames(prefix string) []string {
+	seen := make(map[string]bool)
+	for _, key := range envKeys(prefix) {
+		rest := strings.TrimPrefix(key, prefix)
+
+		// Prefer the longest matching field so FILE_PATH wins over a shorter suffix.
+		// Fields such as TLS_CA_FILE end in _FILE themselves, so match both forms.
+		name := ""
+		for _, variable := range []string{rest, strings.TrimSuffix(rest, "_FILE")} {
+			for _, field := range connectionEnvFields {
+				candidate := strings.TrimSuffix(variable, "_"+field)
+				if candidate != variable && candidate != "" && (name == "" || len(candidate) < len(name)) {
+					name = candidate
+				}
+			}
+		}
+		// DB_CONN_REPORTS_SLAVE_0_HOST belongs to a slave of the reports connection
+		if i := strings.LastIndex(name, "_SLAVE_"); i > 0 {
+			if _, err := strconv.Atoi(name[i+len("_SLAVE_"):]); err == nil {
+				name = name[:i]
+			}
+		}
+		if name != "" {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envKeys returns the names of all environment variables starting with prefix.
+func envKeys(prefix string) []string {
+	var keys []string
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// envReader reads prefixed environment variables into typed fields,
+// collecting conversion errors instead of stopping at the first one.
+type envReader struct {
+	prefix string
+	errs   []error
+}
+
+// lookup returns the value of a variable, falling back to the contents of the
+// file named by <key>_FILE.
+func (r *envReader) lookup(name string) (string, bool) {
+	key := r.prefix + name
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok {
+		return "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		return "", false
+	}
+
+	// Secret files commonly end with a newline
+	return strings.TrimRight(string(data), "\r\n"), true
+}
+
+func (r *envReader) string(name string, dst *string) {
+	if value, ok := r.lookup(name); ok {
+		*dst = value
+	}
+}
+
+func (r *envReader) int(name string, dst *int) {
+	if value, ok := r.lookup(name); ok {
+		r.parseInt(name, value, dst)
+	}
+}
+
+func (r *envReader) intPtr(name string, dst **int) {
+	var n int
+	if value, ok := r.lookup(name); ok && r.parseInt(name, value, &n) {
+		*dst = &n
+	}
+}
+
+func (r *envReader) bool(name string, dst *bool) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		r.invalid(name, "boolean", value, err)
+		return
+	}
+	*dst = b
+}
+
+func (r *envReader) float(name string, dst *float64) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		r.invalid(name, "number", value, err)
+		return
+	}
+	*dst = f
+}
+
+func (r *envReader) duration(name string, dst *time.Duration) {
+	if value, ok := r.lookup(name); ok {
+		r.parseDuration(name, value, dst)
+	}
+}
+
+func (r *envReader) durationPtr(name string, dst **time.Duration) {
+	var d time.Duration
+	if value, ok := r.lookup(name); ok && r.parseDuration(name, value, &d) {
+		*dst = &d
+	}
+}
+
+func (r *envReader) params(name string, dst *map[string]string) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		r.invalid(name, "query string", value, err)
+		return
+	}
+	params := make(map[string]string, len(query))
+	for key := range query {
+		params[key] = query.Get(key)
+	}
+	*dst = params
+}
+
+func (r *envReader) parseInt(name, value string, dst *int) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		r.invalid(name, "integer", value, err)
+		return false
+	}
+	*dst = n
+	return true
+}
+
+func (r *envReader) parseDuration(name, value string, dst *time.Duration) bool {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		r.invalid(name, "duration", value, err)
+		return false
+	}
+	*dst = d
+	return true
+}
+
+// invalid records a conversion error for a variable.
+func (r *envReader) invalid(name, kind, value string, err error) {
+	r.errs = append(r.errs, fmt.Errorf("%s%s: invalid %s %q: %w", r.prefix, name, kind, value, err))
+}
+
+// connection reads a ConnectionConfig from variables starting with prefix
+// (relative to the reader prefix).
+func (r *envReader) connection(prefix string, c *ConnectionConfig) {
+	r.string(prefix+"DRIVER", &c.Driver)
+	r.string(prefix+"HOST", &c.Host)
+	r.int(prefix+"PORT", &c.Port)
+	r.string(prefix+"DATABASE", &c.Database)
+	r.string(prefix+"USERNAME", &c.Username)
+	r.string(prefix+"PASSWORD", &c.Password)
+	r.string(prefix+"FILE_PATH", &c.FilePath)
+	r.intPtr(prefix+"MAX_OPEN_CONNS", &c.MaxOpenConns)
+	r.intPtr(prefix+"MAX_IDLE_CONNS", &c.MaxIdleConns)
+	r.durationPtr(prefix+"CONN_MAX_LIFETIME", &c.ConnMaxLifetime)
+	r.durationPtr(prefix+"CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime)
+	r.string(prefix+"NAME", &c.Name)
+	r.int(prefix+"WEIGHT", &c.Weight)
+	r.params(prefix+"TAGS", &c.Tags)
+	r.string(prefix+"CHARSET", &c.Charset)
+	r.string(prefix+"TIMEZONE", &c.Timezone)
+	r.bool(prefix+"PARSE_TIME", &c.ParseTime)
+	r.string(prefix+"SSL_MODE", &c.SSLMode)
+	r.string(prefix+"SCHEMA", &c.Schema)
+	r.string(prefix+"INSTANCE", &c.Instance)
+	r.string(prefix+"ENCRYPT", &c.Encrypt)
+	r.params(prefix+"PARAMS", &c.Params)
+	r.tls(prefix+"TLS_", &c.TLS)
+}
+
+// slaves reads the indexed slaves <prefix>SLAVE_<INDEX>_* (relative to the
+// reader prefix).
+func (r *envReader) slaves(prefix string) []ConnectionConfig {
+	indexes, err := envSlaveIndexes(r.prefix + prefix + "SLAVE_")
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+
+	var slaves []ConnectionConfig
+	for _, index := range indexes {
+		var slave ConnectionConfig
+		r.connection(fmt.Sprintf("%sSLAVE_%d_", prefix, index), &slave)
+		slaves = append(slaves, slave)
+	}
+	return slaves
+}
+
+// tls reads a TLSConfig from variables starting with prefix.
+func (r *envReader) tls(prefix string, t *TLSConfig) {
+	r.bool(prefix+"ENABLED", &t.Enabled)
+	r.string(prefix+"CA_FILE", &t.CAFile)
+	r.string(prefix+"CERT_FILE", &t.CertFile)
+	r.string(prefix+"KEY_FILE", &t.KeyFile)
+	r.string(prefix+"SERVER_NAME", &t.ServerName)
+	r.string(prefix+"VERIFY_MODE", &t.VerifyMode)
+}
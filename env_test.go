@@ -0,0 +1,192 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfigFromEnv_Defaults tests that unset variables keep DefaultConfig values
+func TestConfigFromEnv_Defaults(t *testing.T) {
+	config, err := ConfigFromEnv("DGTEST_EMPTY")
+	require.NoError(t, err)
+
+	defaults := DefaultConfig()
+	assert.Equal(t, defaults.Driver, config.Driver)
+	assert.Equal(t, defaults.Port, config.Port)
+	assert.Equal(t, defaults.MaxOpenConns, config.MaxOpenConns)
+	assert.Equal(t, defaults.SlaveStrategy, config.SlaveStrategy)
+	assert.NotNil(t, config.Connections)
+}
+
+// TestConfigFromEnv_MainConfig tests loading the main connection and nested settings
+func TestConfigFromEnv_MainConfig(t *testing.T) {
+	t.Setenv("DB_DRIVER", "postgres")
+	t.Setenv("DB_HOST", "pg.internal")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_DATABASE", "app")
+	t.Setenv("DB_USERNAME", "app")
+	t.Setenv("DB_PASSWORD", "secret")
+	t.Setenv("DB_SSL_MODE", "require")
+	t.Setenv("DB_SCHEMA", "tenant")
+	t.Setenv("DB_PARSE_TIME", "false")
+	t.Setenv("DB_MAX_OPEN_CONNS", "25")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "5m")
+	t.Setenv("DB_LOG_LEVEL", "info")
+	t.Setenv("DB_SLOW_QUERY_ENABLED", "true")
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "250ms")
+	t.Setenv("DB_RETRY_ENABLED", "true")
+	t.Setenv("DB_RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("DB_RETRY_BACKOFF_FACTOR", "1.5")
+	t.Setenv("DB_DEFAULT_CONNECTION", "primary")
+	t.Setenv("DB_STRICT_CONNECTIONS", "true")
+	t.Setenv("DB_TENANTS_MAX_POOLS", "20")
+	t.Setenv("DB_TENANTS_IDLE_TIMEOUT", "2m")
+	t.Setenv("DB_TENANTS_MAX_OPEN_CONNS", "4")
+	t.Setenv("DB_PARAMS", "application_name=api&connect_timeout=5")
+
+	config, err := ConfigFromEnv("DB")
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres", config.Driver)
+	assert.Equal(t, "pg.internal", config.Host)
+	assert.Equal(t, 5432, config.Port)
+	assert.Equal(t, "app", config.Database)
+	assert.Equal(t, "secret", config.Password)
+	assert.Equal(t, "require", config.SSLMode)
+	assert.Equal(t, "tenant", config.Schema)
+	assert.False(t, config.ParseTime)
+	assert.Equal(t, 25, config.MaxOpenConns)
+	assert.Equal(t, 30*time.Minute, config.ConnMaxLifetime)
+	assert.Equal(t, 5*time.Minute, config.ConnMaxIdleTime)
+	assert.Equal(t, "info", config.LogLevel)
+	assert.True(t, config.SlowQuery.Enabled)
+	assert.Equal(t, 250*time.Millisecond, config.SlowQuery.Threshold)
+	assert.True(t, config.Retry.Enabled)
+	assert.Equal(t, 5, config.Retry.MaxAttempts)
+	assert.Equal(t, 1.5, config.Retry.BackoffFactor)
+	assert.Equal(t, "primary", config.DefaultConnection)
+	assert.True(t, config.StrictConnections)
+	assert.Equal(t, 20, config.Tenants.MaxPools)
+	assert.Equal(t, 2*time.Minute, config.Tenants.IdleTimeout)
+	assert.Equal(t, 4, config.Tenants.MaxOpenConns)
+	assert.Nil(t, config.Tenants.Resolver)
+	assert.Equal(t, map[string]string{"application_name": "api", "connect_timeout": "5"}, config.Params)
+
+	// DB_CONN_MAX_* belong to the main pool, not to a named connection
+	assert.Empty(t, config.Connections)
+}
+
+// TestConfigFromEnv_SlavesAndConnections tests indexed slaves and named connections
+func TestConfigFromEnv_SlavesAndConnections(t *testing.T) {
+	t.Setenv("DB_READ_WRITE_SPLITTING", "true")
+	t.Setenv("DB_MASTER_HOST", "master.internal")
+	t.Setenv("DB_SLAVE_0_HOST", "replica-0.internal")
+	t.Setenv("DB_SLAVE_0_WEIGHT", "3")
+	t.Setenv("DB_SLAVE_0_NAME", "replica-eu")
+	t.Setenv("DB_SLAVE_0_TAGS", "zone=eu&tier=fast")
+	t.Setenv("DB_SLAVE_1_HOST", "replica-1.internal")
+	t.Setenv("DB_SLAVE_1_MAX_OPEN_CONNS", "10")
+	t.Setenv("DB_CONN_ANALYTICS_DRIVER", "postgres")
+	t.Setenv("DB_CONN_ANALYTICS_HOST", "analytics.internal")
+	t.Setenv("DB_CONN_BILLING_EU_HOST", "billing-eu.internal")
+	t.Setenv("DB_CONN_BILLING_EU_CONN_MAX_LIFETIME", "1h")
+	t.Setenv("DB_CONN_BILLING_EU_TLS_ENABLED", "true")
+	t.Setenv("DB_CONN_BILLING_EU_TLS_CA_FILE", "/etc/ssl/ca.pem")
+	t.Setenv("DB_CONN_BILLING_EU_SLAVE_STRATEGY", "weighted")
+	t.Setenv("DB_CONN_BILLING_EU_SLAVE_0_HOST", "billing-eu-replica-0.internal")
+	t.Setenv("DB_CONN_BILLING_EU_SLAVE_0_WEIGHT", "2")
+	t.Setenv("DB_CONN_BILLING_EU_SLAVE_1_HOST", "billing-eu-replica-1.internal")
+
+	config, err := ConfigFromEnv("DB_")
+	require.NoError(t, err)
+
+	assert.True(t, config.ReadWriteSplitting)
+	assert.Equal(t, "master.internal", config.Master.Host)
+
+	require.Len(t, config.Slaves, 2)
+	assert.Equal(t, "replica-0.internal", config.Slaves[0].Host)
+	assert.Equal(t, 3, config.Slaves[0].Weight)
+	assert.Equal(t, "replica-eu", config.Slaves[0].Name)
+	assert.Equal(t, map[string]string{"zone": "eu", "tier": "fast"}, config.Slaves[0].Tags)
+	assert.Nil(t, config.Slaves[0].MaxOpenConns)
+	assert.Equal(t, "replica-1.internal", config.Slaves[1].Host)
+	require.NotNil(t, config.Slaves[1].MaxOpenConns)
+	assert.Equal(t, 10, *config.Slaves[1].MaxOpenConns)
+
+	require.Len(t, config.Connections, 2)
+	assert.Equal(t, "postgres", config.Connections["analytics"].Driver)
+	assert.Equal(t, "analytics.internal", config.Connections["analytics"].Host)
+	assert.Equal(t, "billing-eu.internal", config.Connections["billing_eu"].Host)
+	require.NotNil(t, config.Connections["billing_eu"].ConnMaxLifetime)
+	assert.Equal(t, time.Hour, *config.Connections["billing_eu"].ConnMaxLifetime)
+	assert.True(t, config.Connections["billing_eu"].TLS.Enabled)
+	assert.Equal(t, "/etc/ssl/ca.pem", config.Connections["billing_eu"].TLS.CAFile)
+
+	// Slaves of a named connection do not define connections of their own
+	assert.Equal(t, "weighted", config.Connections["billing_eu"].SlaveStrategy)
+	require.Len(t, config.Connections["billing_eu"].Slaves, 2)
+	assert.Equal(t, "billing-eu-replica-0.internal", config.Connections["billing_eu"].Slaves[0].Host)
+	assert.Equal(t, 2, config.Connections["billing_eu"].Slaves[0].Weight)
+	assert.Equal(t, "billing-eu-replica-1.internal", config.Connections["billing_eu"].Slaves[1].Host)
+	assert.Empty(t, config.Connections["analytics"].Slaves)
+}
+
+// TestConfigFromEnv_SlaveIndexGap tests that non-contiguous slave indexes are rejected
+func TestConfigFromEnv_SlaveIndexGap(t *testing.T) {
+	t.Setenv("DB_SLAVE_0_HOST", "replica-0.internal")
+	t.Setenv("DB_SLAVE_2_HOST", "replica-2.internal")
+
+	_, err := ConfigFromEnv("DB")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_SLAVE_1_*")
+}
+
+// TestConfigFromEnv_FileSuffix tests reading values from secret files
+func TestConfigFromEnv_FileSuffix(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "db_password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+
+	t.Setenv("DB_PASSWORD_FILE", passwordFile)
+	t.Setenv("DB_CONN_REPORTING_PASSWORD_FILE", passwordFile)
+
+	config, err := ConfigFromEnv("DB")
+	require.NoError(t, err)
+
+	assert.Equal(t, "from-file", config.Password)
+	assert.Equal(t, "from-file", config.Connections["reporting"].Password)
+
+	// A directly set variable wins over the file
+	t.Setenv("DB_PASSWORD", "direct")
+	config, err = ConfigFromEnv("DB")
+	require.NoError(t, err)
+	assert.Equal(t, "direct", config.Password)
+
+	// A missing file is reported
+	t.Setenv("DB_HOST_FILE", filepath.Join(dir, "missing"))
+	_, err = ConfigFromEnv("DB")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_HOST_FILE")
+}
+
+// TestConfigFromEnv_ConversionErrors tests that all conversion errors are reported
+func TestConfigFromEnv_ConversionErrors(t *testing.T) {
+	t.Setenv("DB_PORT", "abc")
+	t.Setenv("DB_PARSE_TIME", "maybe")
+	t.Setenv("DB_RETRY_INITIAL_DELAY", "soon")
+	t.Setenv("DB_SLAVE_0_MAX_IDLE_CONNS", "many")
+
+	_, err := ConfigFromEnv("DB")
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), `DB_PORT: invalid integer "abc"`)
+	assert.Contains(t, err.Error(), `DB_PARSE_TIME: invalid boolean "maybe"`)
+	assert.Contains(t, err.Error(), `DB_RETRY_INITIAL_DELAY: invalid duration "soon"`)
+	assert.Contains(t, err.Error(), `DB_SLAVE_0_MAX_IDLE_CONNS: invalid integer "many"`)
+}
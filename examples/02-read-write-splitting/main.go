@@ -102,7 +102,7 @@ func main() {
 	// Read from specific slave
 	fmt.Println("\n6. Read from specific slave (index 0)...")
 	var slave0Products []Product
-	manager.Slave(0).Find(&slave0Products)
+	manager.Slave("slave_0").Find(&slave0Products)
 	fmt.Printf("   ✅ Read %d products from slave 0\n", len(slave0Products))
 
 	// ========== Health Check ==========
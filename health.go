@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -34,17 +35,25 @@ func (m *Manager) DetailedHealthCheck() map[string]ConnectionHealth {
 	result := make(map[string]ConnectionHealth)
 
 	// Check primary connection
-	result["primary"] = m.checkConnectionHealth(m.db, "primary")
+	result["primary"] = m.checkConnectionHealth(m.primary(), "primary")
 
 	// Check master/slave connections if read/write splitting is enabled
 	if m.config.ReadWriteSplitting {
+		m.ensureSlaves()
 		if m.master != nil {
 			result["master"] = m.checkConnectionHealth(m.master, "master")
 		}
 
-		for i, slave := range m.slaves {
-			name := fmt.Sprintf("slave_%d", i)
-			result[name] = m.checkConnectionHealth(slave, name)
+		for _, r := range m.replicaSnapshot() {
+			if !r.connected() {
+				result[r.name] = ConnectionHealth{
+					Status:      HealthStatusUnhealthy,
+					Error:       r.err,
+					LastChecked: time.Now(),
+				}
+				continue
+			}
+			result[r.name] = m.checkConnectionHealth(r.db, r.name)
 		}
 	}
 
@@ -55,11 +64,32 @@ func (m *Manager) DetailedHealthCheck() map[string]ConnectionHealth {
 	}
 	m.connMu.RUnlock()
 
+	// Check the slaves of named connections, as <connection>/<slave>
+	for _, c := range m.clusterSnapshot() {
+		for _, r := range c.replicaSnapshot() {
+			name := c.replicaKey(r.name)
+			if !r.connected() {
+				result[name] = ConnectionHealth{
+					Status:      HealthStatusUnhealthy,
+					Error:       r.err,
+					LastChecked: time.Now(),
+				}
+				continue
+			}
+			result[name] = m.checkConnectionHealth(r.db, name)
+		}
+	}
+
 	return result
 }
 
 // checkConnectionHealth performs a health check on a single connection.
 func (m *Manager) checkConnectionHealth(db *gorm.DB, name string) ConnectionHealth {
+	return m.checkConnectionHealthContext(context.Background(), db, name)
+}
+
+// checkConnectionHealthContext performs a health check bounded by ctx.
+func (m *Manager) checkConnectionHealthContext(ctx context.Context, db *gorm.DB, name string) ConnectionHealth {
 	start := time.Now()
 
 	// Get underlying sql.DB
@@ -73,20 +103,11 @@ func (m *Manager) checkConnectionHealth(db *gorm.DB, name string) ConnectionHeal
 	}
 
 	// Ping to check connectivity and measure latency
-	err = sqlDB.Ping()
+	err = sqlDB.PingContext(ctx)
 	latency := time.Since(start)
 
 	// Get pool statistics
-	stats := sqlDB.Stats()
-	poolStats := PoolStats{
-		OpenConnections:   stats.OpenConnections,
-		InUse:             stats.InUse,
-		Idle:              stats.Idle,
-		WaitCount:         stats.WaitCount,
-		WaitDuration:      stats.WaitDuration,
-		MaxIdleClosed:     stats.MaxIdleClosed,
-		MaxLifetimeClosed: stats.MaxLifetimeClosed,
-	}
+	stats := poolStats(sqlDB.Stats())
 
 	// Determine health status based on ping result and latency
 	status := HealthStatusHealthy
@@ -101,7 +122,7 @@ func (m *Manager) checkConnectionHealth(db *gorm.DB, name string) ConnectionHeal
 		Status:      status,
 		Latency:     latency,
 		Error:       err,
-		Stats:       poolStats,
+		Stats:       stats,
 		LastChecked: time.Now(),
 	}
 }
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// lazyInit runs an initialization function until it succeeds once. Unlike
+// sync.Once, a failed initialization is attempted again by the next caller.
+type lazyInit struct {
+	done atomic.Bool
+	mu   sync.Mutex
+
+	attempts atomic.Uint64 // Finished initializations
+	running  atomic.Bool
+	lastErr  atomic.Pointer[error]
+
+	// The placeholder has its own lock so failing callers do not wait for a
+	// running initialization
+	failedMu sync.Mutex
+	failed   *gorm.DB // guarded by failedMu
+}
+
+// do runs fn unless an earlier call succeeded. Concurrent callers wait for
+// the running initialization and share its result. Once an initialization
+// failed, callers arriving while it is attempted again get the last error
+// right away instead of waiting for the retries.
+func (l *lazyInit) do(fn func() error) error {
+	if l.done.Load() {
+		return nil
+	}
+
+	attempts := l.attempts.Load()
+	if l.running.Load() && l.lastErr.Load() != nil {
+		return l.err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done.Load() {
+		return nil
+	}
+	if l.attempts.Load() != attempts {
+		// The initialization failed while this caller waited
+		return l.err()
+	}
+
+	l.running.Store(true)
+	err := fn()
+	l.running.Store(false)
+	l.attempts.Add(1)
+
+	if err != nil {
+		l.lastErr.Store(&err)
+		return err
+	}
+
+	l.done.Store(true)
+	return nil
+}
+
+// err returns the error of the last failed initialization.
+func (l *lazyInit) err() error {
+	if err := l.lastErr.Load(); err != nil {
+		return *err
+	}
+	return errors.New("database connection is not initialized")
+}
+
+// failedDB returns a *gorm.DB on which every operation fails with the last
+// initialization error. It lets accessors like DB() report connection errors
+// through the usual Error field instead of returning nil.
+func (l *lazyInit) failedDB() *gorm.DB {
+	l.failedMu.Lock()
+	defer l.failedMu.Unlock()
+
+	if l.failed == nil {
+		db, err := gorm.Open(&failedDialector{init: l}, &gorm.Config{
+			Logger:               logger.Discard,
+			DisableAutomaticPing: true,
+		})
+		if err != nil {
+			// failedDialector.Initialize never fails
+			panic(fmt.Sprintf("database: failed to create placeholder connection: %v", err))
+		}
+		l.failed = db
+	}
+	return l.failed
+}
+
+// close releases the placeholder returned by failedDB.
+func (l *lazyInit) close() {
+	l.failedMu.Lock()
+	defer l.failedMu.Unlock()
+
+	if l.failed == nil {
+		return
+	}
+	if sqlDB, err := l.failed.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+	l.failed = nil
+}
+
+// failedConnector is a driver.Connector whose connections fail with the last
+// initialization error of a lazyInit.
+type failedConnector struct {
+	init *lazyInit
+}
+
+func (c failedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, fmt.Errorf("database connection unavailable: %w", c.init.err())
+}
+
+func (c failedConnector) Driver() driver.Driver {
+	return failedDriver(c)
+}
+
+type failedDriver failedConnector
+
+func (d failedDriver) Open(string) (driver.Conn, error) {
+	return failedConnector(d).Connect(context.Background())
+}
+
+// failedDialector is the GORM dialector behind lazyInit.failedDB.
+type failedDialector struct {
+	init *lazyInit
+}
+
+func (d *failedDialector) Name() string {
+	return "unavailable"
+}
+
+func (d *failedDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	db.ConnPool = sql.OpenDB(failedConnector{init: d.init})
+	return nil
+}
+
+func (d *failedDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}}
+}
+
+func (d *failedDialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d *failedDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (d *failedDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	_ = writer.WriteByte('?')
+}
+
+func (d *failedDialector) QuoteTo(writer clause.Writer, str string) {
+	_, _ = writer.WriteString(str)
+}
+
+func (d *failedDialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// registerCountingSQLiteDriver registers a sqlite driver that counts the dialectors it creates.
+func registerCountingSQLiteDriver(t *testing.T, name string) *atomic.Int32 {
+	t.Helper()
+
+	var opened atomic.Int32
+	RegisterDriver(name, func(config DSNBuilder) (gorm.Dialector, error) {
+		opened.Add(1)
+		return sqlite.Open(config.GetFilePath()), nil
+	})
+	return &opened
+}
+
+// TestManager_Lazy_ConnectsOnFirstUse tests that lazy managers connect on first use
+func TestManager_Lazy_ConnectsOnFirstUse(t *testing.T) {
+	opened := registerCountingSQLiteDriver(t, "test-lazy-sqlite")
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:   "test-lazy-sqlite",
+		Host:     "localhost",
+		FilePath: filepath.Join(dir, "primary.db"),
+		Lazy:     true,
+		Connections: map[string]ConnectionConfig{
+			"analytics": {FilePath: filepath.Join(dir, "analytics.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Equal(t, int32(0), opened.Load(), "nothing should be opened by NewManager")
+	assert.True(t, manager.HasConnection("analytics"))
+
+	require.NoError(t, manager.DB().Exec("CREATE TABLE items (id INTEGER)").Error)
+	assert.Equal(t, int32(1), opened.Load())
+
+	require.NoError(t, manager.Connection("analytics").Exec("SELECT 1").Error)
+	assert.Equal(t, int32(2), opened.Load())
+
+	// Later calls reuse the connections
+	require.NoError(t, manager.DB().Exec("SELECT 1").Error)
+	require.NoError(t, manager.Connection("analytics").Exec("SELECT 1").Error)
+	assert.Equal(t, int32(2), opened.Load())
+}
+
+// TestManager_Lazy_ConcurrentFirstUse tests that concurrent first use connects once
+func TestManager_Lazy_ConcurrentFirstUse(t *testing.T) {
+	opened := registerCountingSQLiteDriver(t, "test-lazy-concurrent")
+
+	config := Config{
+		Driver:   "test-lazy-concurrent",
+		Host:     "localhost",
+		FilePath: filepath.Join(t.TempDir(), "app.db"),
+		Lazy:     true,
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, manager.DB().Exec("SELECT 1").Error)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), opened.Load())
+}
+
+// TestManager_Lazy_ConnectionError tests that lazy connection errors reach the caller
+func TestManager_Lazy_ConnectionError(t *testing.T) {
+	var attempts atomic.Int32
+	RegisterDriver("test-lazy-unavailable", func(config DSNBuilder) (gorm.Dialector, error) {
+		attempts.Add(1)
+		return nil, errors.New("connection refused")
+	})
+
+	config := Config{
+		Driver:   "test-lazy-unavailable",
+		Host:     "db.internal",
+		Lazy:     true,
+		LogLevel: "silent",
+		Connections: map[string]ConnectionConfig{
+			"reports": {Host: "reports.internal"},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	err = manager.DB().Exec("SELECT 1").Error
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+
+	var count int64
+	err = manager.DB().Table("users").Count(&count).Error
+	require.Error(t, err)
+
+	assert.Error(t, manager.Ping())
+	assert.Error(t, manager.AutoMigrate(&TestUser{}))
+
+	// A failed named connection must not silently use the primary
+	err = manager.Connection("reports").Exec("SELECT 1").Error
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reports")
+
+	// Failed initialization is retried on the next use
+	assert.Greater(t, attempts.Load(), int32(3))
+}
+
+// TestManager_Lazy_FailsFastWhileRetrying tests that callers get the last error while a failed connection is retried
+func TestManager_Lazy_FailsFastWhileRetrying(t *testing.T) {
+	var attempts atomic.Int32
+	gate := make(chan struct{})
+	RegisterDriver("test-lazy-retrying", func(config DSNBuilder) (gorm.Dialector, error) {
+		if attempts.Add(1) > 1 {
+			<-gate
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	config := Config{
+		Driver:   "test-lazy-retrying",
+		Host:     "db.internal",
+		Lazy:     true,
+		LogLevel: "silent",
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+	release := sync.OnceFunc(func() { close(gate) })
+	defer release()
+
+	require.Error(t, manager.Ping())
+
+	retried := make(chan error, 1)
+	go func() { retried <- manager.Ping() }()
+	require.Eventually(t, func() bool { return attempts.Load() == 2 }, time.Second, time.Millisecond)
+
+	// The retry is running; other callers do not wait for it
+	for i := 0; i < 5; i++ {
+		err := manager.DB().Exec("SELECT 1").Error
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connection refused")
+	}
+	assert.Equal(t, int32(2), attempts.Load())
+
+	release()
+	assert.Error(t, <-retried)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+// TestManager_Lazy_ReadWriteSplitting tests that slaves are connected on first read
+func TestManager_Lazy_ReadWriteSplitting(t *testing.T) {
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		Lazy:               true,
+		ReadWriteSplitting: true,
+		SlaveStrategy:      "round-robin",
+		Slaves: []ConnectionConfig{
+			{FilePath: filepath.Join(dir, "slave.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Nil(t, manager.db)
+	assert.Empty(t, manager.connectedReplicas())
+
+	require.NoError(t, manager.DB().Exec("SELECT 1").Error)
+	assert.Empty(t, manager.connectedReplicas(), "slaves should connect on first read")
+
+	require.NoError(t, manager.Read().Exec("SELECT 1").Error)
+	assert.Len(t, manager.connectedReplicas(), 1)
+}
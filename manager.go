@@ -3,9 +3,12 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"math/rand"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,24 +20,48 @@ type contextKey string
 // skipRoutingKey is the context key for skipping automatic routing.
 const skipRoutingKey contextKey = "dgcore:skip_routing"
 
+// ErrConnectionNotFound is returned for a named connection that is not configured.
+var ErrConnectionNotFound = errors.New("connection not found")
+
 // Manager manages database connections with support for read/write splitting and multi-connection.
 type Manager struct {
 	// Primary connection
-	db     *gorm.DB
-	config Config
-	logger Logger // Type-safe logger interface
+	db       *gorm.DB
+	config   Config
+	configMu sync.RWMutex // Guards the config fields changed by Reload
+	reloadMu sync.Mutex   // Serializes Reload
+	logger   Logger       // Type-safe logger interface
+
+	// Initialization of the primary/master and slave connections; in lazy
+	// mode these run on first use instead of in NewManager
+	primaryInit lazyInit
+	slavesInit  lazyInit
 
 	// ========== Read/Write Splitting ==========
 	master *gorm.DB
-	slaves []*gorm.DB
 	plugin *ReadWritePlugin
 
-	slaveIndex int
-	slaveMu    sync.Mutex
+	replicas []*replica // Replica registry, in Config.Slaves order
+	selector ReplicaSelector
+	slaveMu  sync.Mutex
+	monitor  *healthMonitor
 
 	// ========== Multi-Connection Support ==========
 	connections map[string]*gorm.DB
+	connInit    map[string]*lazyInit // Pending lazy named connections
+	clusters    map[string]*cluster  // Named connections with slaves
 	connMu      sync.RWMutex
+
+	// ========== Multi-Tenancy ==========
+	tenants tenantCache
+
+	// ========== Shutdown ==========
+	closed     atomic.Bool
+	closeOnce  sync.Once
+	closeErr   error
+	closedInit lazyInit // Placeholder connection handed out after shutdown
+
+	missingInit lazyInit // Placeholder for unknown names with StrictConnections
 }
 
 // NewManager creates a new database manager with the given configuration and logger.
@@ -50,131 +77,227 @@ type Manager struct {
 //	    log.Fatal(err)
 //	}
 func NewManager(config Config, logger Logger) (*Manager, error) {
+	return NewManagerContext(context.Background(), config, logger)
+}
+
+// NewManagerContext creates a new database manager like NewManager, bounding all
+// connection attempts by ctx. The retry policy in config.Retry applies to the
+// primary, master, slave and named connections; retrying stops as soon as ctx is
+// cancelled, so a shutting-down process does not block in the startup retry loop.
+// With retry enabled, connection failures are reported as *ConnectError.
+//
+// With config.Lazy, no connection is opened here; see Config.Lazy.
+func NewManagerContext(ctx context.Context, config Config, logger Logger) (*Manager, error) {
 	// Validate configuration first
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Keep a copy of the topology so Reload can diff against it, and share the
+	// settings Reload changes with every connection
+	config.Slaves = slices.Clone(config.Slaves)
+	config.Connections = maps.Clone(config.Connections)
+	config.Shards = maps.Clone(config.Shards)
+	config.live = newLiveSettings(config)
+
 	manager := &Manager{
 		config:      config,
 		logger:      logger,
+		selector:    config.ReplicaSelector,
 		connections: make(map[string]*gorm.DB),
+		clusters:    make(map[string]*cluster),
+	}
+	if manager.selector == nil {
+		// The strategy name was checked by Validate
+		manager.selector, _ = newReplicaSelector(config.SlaveStrategy)
+	}
+
+	// Lazy mode: connect on first use
+	if config.Lazy {
+		manager.connInit = make(map[string]*lazyInit, len(config.Connections))
+		for name := range config.Connections {
+			manager.connInit[name] = &lazyInit{}
+		}
+		manager.startMonitorIfEnabled()
+		return manager, nil
 	}
 
-	// Setup primary/default connection
-	if err := manager.setupPrimaryConnection(); err != nil {
+	// Setup primary/default connection and master
+	if err := manager.primaryInit.do(func() error { return manager.setupPrimary(ctx) }); err != nil {
 		return nil, err
 	}
 
-	// Setup read/write splitting if enabled
-	if config.ReadWriteSplitting {
-		if err := manager.setupReadWriteSplitting(); err != nil {
-			return nil, err
-		}
+	// Setup slaves if read/write splitting is enabled
+	if err := manager.slavesInit.do(func() error { return manager.setupSlaves(ctx) }); err != nil {
+		_ = manager.Close()
+		return nil, err
 	}
 
 	// Setup named connections if configured
 	if len(config.Connections) > 0 {
-		if err := manager.setupNamedConnections(); err != nil {
+		if err := manager.setupNamedConnections(ctx); err != nil {
+			_ = manager.Close()
 			return nil, err
 		}
 	}
 
+	manager.startMonitorIfEnabled()
+
 	return manager, nil
 }
 
-func (m *Manager) setupPrimaryConnection() error {
-	// Create primary connection
-	db, err := connect(m.config, m.logger)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+// startMonitorIfEnabled starts the background slave monitor when health or
+// replication lag checks are configured.
+func (m *Manager) startMonitorIfEnabled() {
+	if m.config.monitorEnabled() {
+		m.startHealthMonitor()
 	}
+}
 
-	// Configure connection pool
-	sqlDB, err := db.DB()
-	if err != nil {
+// setupPrimary connects the primary connection and, with read/write splitting,
+// the master connection and the routing plugin.
+func (m *Manager) setupPrimary(ctx context.Context) error {
+	if err := m.setupPrimaryConnection(ctx); err != nil {
 		return err
 	}
 
-	// Special handling for SQLite :memory: databases
-	// They must use a single connection because each connection has its own database
-	if m.config.Driver == "sqlite" && (m.config.Database == ":memory:" || m.config.FilePath == ":memory:") {
-		sqlDB.SetMaxOpenConns(1)
-		sqlDB.SetMaxIdleConns(1)
-	} else {
-		sqlDB.SetMaxOpenConns(m.config.MaxOpenConns)
-		sqlDB.SetMaxIdleConns(m.config.MaxIdleConns)
+	if m.config.ReadWriteSplitting {
+		if err := m.setupMaster(ctx); err != nil {
+			if sqlDB, dbErr := m.db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+			m.db, m.master = nil, nil
+			return err
+		}
 	}
 
-	sqlDB.SetConnMaxLifetime(m.config.ConnMaxLifetime)
-	sqlDB.SetConnMaxIdleTime(m.config.ConnMaxIdleTime)
+	return nil
+}
 
-	m.db = db
-	m.master = db // Master is primary by default
+func (m *Manager) setupPrimaryConnection(ctx context.Context) error {
+	// Create primary connection (pool and slow query logging are configured by connect)
+	db, err := connect(ctx, m.settings(), m.logger)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
 
 	// Auto migrate if configured
 	if m.config.AutoMigrate && len(m.config.Models) > 0 {
-		if err := m.AutoMigrate(m.config.Models...); err != nil {
+		if err := migrateOnMaster(db, m.config.Models...); err != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
 			return fmt.Errorf("auto migration failed: %w", err)
 		}
 	}
 
-	// Setup slow query logging if enabled
-	if m.config.SlowQuery.Enabled {
-		slowQueryPlugin := NewSlowQueryPlugin(m.config.SlowQuery, m.logger)
-		if err := m.db.Use(slowQueryPlugin); err != nil {
-			return fmt.Errorf("failed to register slow query plugin: %w", err)
-		}
-	}
+	m.db = db
+	m.master = db // Master is primary by default
 
 	return nil
 }
 
-func (m *Manager) setupReadWriteSplitting() error {
+// connectNode opens a master, slave or named connection with the settings it
+// inherits from the main config. name identifies it in slow query logs.
+func (m *Manager) connectNode(ctx context.Context, name string, config ConnectionConfig) (*gorm.DB, error) {
+	settings := m.settings()
+	return openConnection(ctx, name, settings.inherit(config), settings, m.logger)
+}
+
+// settings returns a copy of the config, including the changes made by Reload.
+func (m *Manager) settings() Config {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config
+}
+
+func (m *Manager) setupMaster(ctx context.Context) error {
 	// Connect to master
 	if m.config.Master.Host != "" {
-		master, err := connectWithConfig(m.config.Master, m.logger)
+		master, err := m.connectNode(ctx, "master", m.config.Master)
 		if err != nil {
 			return fmt.Errorf("failed to connect to master: %w", err)
 		}
 		m.master = master
 	}
 
-	// Connect to slaves
-	for i, slaveConfig := range m.config.Slaves {
-		slave, err := connectWithConfig(slaveConfig, m.logger)
+	// Enable automatic routing if configured
+	if m.config.AutoRouting {
+		m.plugin = NewReadWritePlugin(m)
+		m.master.Use(m.plugin)
+		m.logInfo("Automatic read/write routing enabled")
+	}
+
+	return nil
+}
+
+func (m *Manager) setupSlaves(ctx context.Context) error {
+	if !m.config.ReadWriteSplitting {
+		return nil
+	}
+
+	// Connect to slaves; a slave that fails to connect stays in the registry
+	// as failed so names, weights and tags keep matching their config
+	slaves := m.settings().Slaves
+	replicas := make([]*replica, 0, len(slaves))
+	connected := 0
+	for i, slaveConfig := range slaves {
+		r := newReplica(replicaName(slaveConfig, i), slaveConfig)
+		replicas = append(replicas, r)
+
+		slave, err := m.connectNode(ctx, r.name, slaveConfig)
 		if err != nil {
-			m.logWarn("Failed to connect to slave", "index", i, "error", err)
+			// A cancelled context aborts startup instead of skipping the slave
+			if ctx.Err() != nil {
+				closeReplicas(replicas)
+				return fmt.Errorf("failed to connect to slave %s: %w", r.name, err)
+			}
+			m.logWarn("Failed to connect to slave", "slave", r.name, "error", err)
+			r.state, r.err = ReplicaFailed, err
 			continue
 		}
-		m.slaves = append(m.slaves, slave)
+		r.db, r.state = slave, ReplicaConnected
+		r.inRotation = true
+		connected++
 	}
 
-	if len(m.slaves) == 0 {
+	if connected == 0 {
 		m.logWarn("No slaves available, using master for reads")
 	}
 
-	// Enable automatic routing if configured
-	if m.config.AutoRouting {
-		m.plugin = NewReadWritePlugin(m)
-		m.master.Use(m.plugin)
-		m.logInfo("Automatic read/write routing enabled")
-	}
+	m.slaveMu.Lock()
+	m.replicas = replicas
+	m.slaveMu.Unlock()
 
 	return nil
 }
 
-func (m *Manager) setupNamedConnections() error {
+// closeReplicas closes the pools of the connected replicas.
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		if r.db == nil {
+			continue
+		}
+		if sqlDB, err := r.db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	}
+}
+
+func (m *Manager) setupNamedConnections(ctx context.Context) error {
+	defaultName := m.config.defaultNamedConnection()
 	for name, connConfig := range m.config.Connections {
-		db, err := connectWithConfig(connConfig, m.logger)
+		db, c, err := m.connectNamed(ctx, name, connConfig)
 		if err != nil {
+			// DB() needs the default connection
+			if ctx.Err() != nil || name == defaultName {
+				return fmt.Errorf("failed to connect to named connection %s: %w", name, err)
+			}
 			m.logWarn("Failed to connect to named connection", "name", name, "error", err)
 			continue
 		}
 
-		m.connMu.Lock()
-		m.connections[name] = db
-		m.connMu.Unlock()
+		_, _, _ = m.storeConnection(name, db, c)
 
 		m.logInfo("Named connection established", "name", name)
 	}
@@ -182,16 +305,114 @@ func (m *Manager) setupNamedConnections() error {
 	return nil
 }
 
+// ========== Lazy Initialization ==========
+
+// ensurePrimary connects the primary and master connections if they are not
+// connected yet. It only dials in lazy mode; otherwise NewManager already did.
+func (m *Manager) ensurePrimary() error {
+	if m.closed.Load() {
+		return ErrManagerClosed
+	}
+	err := m.primaryInit.do(func() error { return m.setupPrimary(context.Background()) })
+	if err != nil {
+		m.logWarn("Failed to connect to database", "error", err)
+	}
+	return err
+}
+
+// ensureSlaves connects the slave connections if they are not connected yet.
+// Slaves that fail to connect are skipped, so reads fall back to master.
+func (m *Manager) ensureSlaves() {
+	_ = m.slavesInit.do(func() error { return m.setupSlaves(context.Background()) })
+}
+
+// primary returns the primary connection, connecting it first in lazy mode.
+// If connecting fails, it returns a connection on which every operation
+// fails with the connection error.
+func (m *Manager) primary() *gorm.DB {
+	if m.closed.Load() {
+		return m.closedDB()
+	}
+	if err := m.ensurePrimary(); err != nil {
+		return m.primaryInit.failedDB()
+	}
+	return m.db
+}
+
+// writer returns the master connection, connecting it first in lazy mode.
+func (m *Manager) writer() *gorm.DB {
+	if m.closed.Load() {
+		return m.closedDB()
+	}
+	if err := m.ensurePrimary(); err != nil {
+		return m.primaryInit.failedDB()
+	}
+	return m.master
+}
+
+// connectLazy connects a pending lazy named connection.
+func (m *Manager) connectLazy(name string, init *lazyInit) (*gorm.DB, error) {
+	err := init.do(func() error {
+		db, c, err := m.connectNamed(context.Background(), name, m.settings().Connections[name])
+		if err != nil {
+			return fmt.Errorf("failed to connect to named connection %s: %w", name, err)
+		}
+
+		if _, _, err := m.storeConnection(name, db, c); err != nil {
+			return err
+		}
+
+		m.logInfo("Named connection established", "name", name)
+		return nil
+	})
+	if err != nil {
+		m.logWarn("Failed to connect to named connection", "name", name, "error", err)
+		return init.failedDB(), err
+	}
+
+	m.connMu.RLock()
+	defer m.connMu.RUnlock()
+	db, exists := m.connections[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrConnectionNotFound, name)
+	}
+	return db, nil
+}
+
 // ========== Primary Connection Methods ==========
 
-// DB returns the primary database connection.
+// DB returns the default database connection: the named connection set by
+// Config.DefaultConnection, or the primary connection.
 // With auto-routing enabled, reads use slaves, writes use master.
+// In lazy mode the connection is opened on first use; if that fails, the error
+// is logged and returned by every operation on the returned *gorm.DB.
 func (m *Manager) DB() *gorm.DB {
-	return m.master
+	if name := m.defaultConnection(); name != "" {
+		return m.Connection(name)
+	}
+	return m.writer()
+}
+
+// defaultConnection returns the named connection returned by DB(), or "" for
+// the primary connection.
+func (m *Manager) defaultConnection() string {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config.defaultNamedConnection()
+}
+
+// stickyWindow returns how long reads stay on master after a write.
+func (m *Manager) stickyWindow() time.Duration {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config.StickyWindow
 }
 
 // Ping tests the database connection.
 func (m *Manager) Ping() error {
+	if err := m.ensurePrimary(); err != nil {
+		return err
+	}
 	sqlDB, err := m.db.DB()
 	if err != nil {
 		return err
@@ -199,189 +420,364 @@ func (m *Manager) Ping() error {
 	return sqlDB.Ping()
 }
 
-// Close closes all database connections.
+// Close closes all database connections immediately, without waiting for
+// running queries like Shutdown does. It returns the joined errors of the
+// pools that failed to close.
 func (m *Manager) Close() error {
-	// Close primary
-	if m.db != nil {
-		sqlDB, _ := m.db.DB()
-		if sqlDB != nil {
-			if err := sqlDB.Close(); err != nil {
-				m.logWarn("Failed to close primary connection", "error", err)
-			}
-		}
-	}
-
-	// Close slaves
-	for i, slave := range m.slaves {
-		if sqlDB, err := slave.DB(); err == nil {
-			if err := sqlDB.Close(); err != nil {
-				m.logWarn("Failed to close slave connection", "index", i, "error", err)
-			}
-		}
-	}
-
-	// Close named connections
-	m.connMu.RLock()
-	defer m.connMu.RUnlock()
-	for name, conn := range m.connections {
-		if sqlDB, err := conn.DB(); err == nil {
-			if err := sqlDB.Close(); err != nil {
-				m.logWarn("Failed to close named connection", "name", name, "error", err)
-			}
-		}
-	}
-
-	return nil
+	m.closeOnce.Do(func() { m.closeErr = m.shutdown(context.Background(), false) })
+	return m.closeErr
 }
 
 // ========== Read/Write Splitting Methods ==========
 
 // Master returns the master connection (forces master for reads).
+// With AutoRouting, replacing the context with WithContext drops the hint;
+// use WithContext(UseMaster(ctx)) instead.
 func (m *Manager) Master() *gorm.DB {
-	return m.master.Session(&gorm.Session{})
+	return m.writer().Session(&gorm.Session{Context: UseMaster(context.Background())})
 }
 
 // Read returns a slave connection for read operations.
 // Falls back to master if no slaves available.
 func (m *Manager) Read() *gorm.DB {
-	if !m.config.ReadWriteSplitting || len(m.slaves) == 0 {
-		return m.master
+	if !m.config.ReadWriteSplitting || m.closed.Load() {
+		return m.writer()
+	}
+	m.ensureSlaves()
+	if slave := m.selectSlave(); slave != nil {
+		return slave
 	}
-	return m.selectSlave()
+	return m.writer()
 }
 
 // Write returns the master connection for write operations.
 func (m *Manager) Write() *gorm.DB {
-	return m.master
+	return m.writer()
 }
 
-// Slave returns a specific slave connection by index.
-func (m *Manager) Slave(index int) *gorm.DB {
-	if index >= 0 && index < len(m.slaves) {
-		return m.slaves[index]
+// Slave returns a specific slave connection by replica name (Name in its
+// ConnectionConfig, or slave_<index> in Config.Slaves). Falls back to master
+// if the slave is unknown or not connected.
+func (m *Manager) Slave(name string) *gorm.DB {
+	if m.closed.Load() {
+		return m.closedDB()
 	}
-	m.logWarn("Invalid slave index, using master", "index", index)
-	return m.master
+	if slave := m.slaveByName(name); slave != nil {
+		return slave
+	}
+	m.logWarn("Slave not available, using master", "slave", name)
+	return m.writer()
 }
 
+// selectSlave picks a slave for a read with the replica selector.
+// Slaves ejected by the health monitor or over MaxReplicationLag are skipped;
+// it returns nil when no slave is available.
 func (m *Manager) selectSlave() *gorm.DB {
+	slave, r := m.pickSlave()
+	if r != nil {
+		r.reads.Done()
+	}
+	return slave
+}
+
+// pickSlave is selectSlave that also returns the registry entry of the slave.
+// The read is counted as in flight on the replica until the caller calls
+// reads.Done on it.
+func (m *Manager) pickSlave() (*gorm.DB, *replica) {
 	m.slaveMu.Lock()
 	defer m.slaveMu.Unlock()
 
-	switch m.config.SlaveStrategy {
-	case "round-robin":
-		slave := m.slaves[m.slaveIndex]
-		m.slaveIndex = (m.slaveIndex + 1) % len(m.slaves)
-		return slave
+	r := selectReplica(m.selector, m.replicas)
+	if r == nil {
+		return nil, nil
+	}
+	return r.db, r
+}
 
-	case "random":
-		idx := rand.Intn(len(m.slaves))
-		return m.slaves[idx]
+// selectReplica picks a routable replica with selector and counts the read
+// as in flight on it. It returns nil when no replica is available. The lock
+// guarding replicas must be held.
+func selectReplica(selector ReplicaSelector, replicas []*replica) *replica {
+	candidates := make([]*replica, 0, len(replicas))
+	for _, r := range replicas {
+		if r.routable() {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
 
-	case "weighted":
-		return m.selectWeightedSlave()
+	infos := make([]ReplicaInfo, len(candidates))
+	for i, r := range candidates {
+		infos[i] = r.info()
+	}
 
-	default:
-		return m.slaves[0]
+	i := selector.Select(infos)
+	if i < 0 || i >= len(candidates) {
+		return nil
 	}
+
+	// Counted until the caller calls reads.Done, so RemoveReplica waits for it
+	candidates[i].reads.Add(1)
+	return candidates[i]
+}
+
+// masterDB implements readWriteRouter.
+func (m *Manager) masterDB() *gorm.DB {
+	return m.master
 }
 
-func (m *Manager) selectWeightedSlave() *gorm.DB {
-	// Calculate total weight
-	totalWeight := 0
-	for _, slaveConfig := range m.config.Slaves {
-		totalWeight += slaveConfig.Weight
+// readSlave implements readWriteRouter, connecting the slaves first in lazy mode.
+func (m *Manager) readSlave() (*gorm.DB, *replica) {
+	m.ensureSlaves()
+	return m.pickSlave()
+}
+
+// observeSlaveLatency adds a latency sample to the moving average of a slave.
+func (m *Manager) observeSlaveLatency(r *replica, latency time.Duration) {
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	r.observe(latency)
+}
+
+// ========== Multi-Connection Methods ==========
+
+// Connection returns a named connection.
+// In lazy mode a configured connection is opened on first use; if that fails,
+// the error is logged and returned by every operation on the returned *gorm.DB.
+// An unknown name falls back to the primary connection, unless
+// Config.StrictConnections is set; then every operation on the returned
+// *gorm.DB fails with ErrConnectionNotFound.
+func (m *Manager) Connection(name string) *gorm.DB {
+	if m.closed.Load() {
+		return m.closedDB()
+	}
+
+	conn, err := m.lookupConnection(name)
+	if conn != nil {
+		// A failed lazy connection returns a placeholder carrying the error
+		return conn
 	}
 
-	if totalWeight == 0 {
-		return m.slaves[0]
+	if m.settings().StrictConnections {
+		m.logWarn("Connection not found", "name", name)
+		return m.missingDB(err)
 	}
 
-	// Select based on weight
-	r := rand.Intn(totalWeight)
-	cumulative := 0
+	m.logWarn("Connection not found, using default", "name", name)
+	return m.primary()
+}
 
-	for i, slaveConfig := range m.config.Slaves {
-		cumulative += slaveConfig.Weight
-		if r < cumulative {
-			return m.slaves[i]
-		}
+// ConnectionE returns a named connection, or an error instead of a fallback.
+// The error wraps ErrConnectionNotFound for an unknown name, is the connection
+// error when a lazy connection fails to open, and is ErrManagerClosed after
+// Close or Shutdown.
+//
+// Example:
+//
+//	db, err := manager.ConnectionE("analytics")
+//	if err != nil {
+//	    return err
+//	}
+func (m *Manager) ConnectionE(name string) (*gorm.DB, error) {
+	if m.closed.Load() {
+		return nil, ErrManagerClosed
 	}
 
-	return m.slaves[0]
+	conn, err := m.lookupConnection(name)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
-// ========== Multi-Connection Methods ==========
+// lookupConnection returns a named connection, opening a pending lazy
+// connection first. If that fails, it returns a placeholder carrying the
+// error along with the error.
+func (m *Manager) lookupConnection(name string) (*gorm.DB, error) {
+	m.connMu.RLock()
+	conn, exists := m.connections[name]
+	init, pending := m.connInit[name]
+	m.connMu.RUnlock()
 
-// Connection returns a named connection.
-func (m *Manager) Connection(name string) *gorm.DB {
+	if exists {
+		return conn, nil
+	}
+	if pending {
+		return m.connectLazy(name, init)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrConnectionNotFound, name)
+}
+
+// ConnectionRead returns a connection for read operations on a named
+// connection: one of its slaves, chosen with its SlaveStrategy. Falls back to
+// the named connection itself when it has no slaves or none is available.
+// Unknown names are handled like Connection.
+func (m *Manager) ConnectionRead(name string) *gorm.DB {
+	conn := m.Connection(name)
+	if c := m.cluster(name); c != nil && !m.closed.Load() {
+		return c.read()
+	}
+	return conn
+}
+
+// ConnectionWrite returns the master of a named connection, also for reads.
+// With AutoRouting, replacing the context with WithContext drops the hint;
+// use WithContext(UseMaster(ctx)) instead.
+func (m *Manager) ConnectionWrite(name string) *gorm.DB {
+	return m.Connection(name).Session(&gorm.Session{Context: UseMaster(context.Background())})
+}
+
+// cluster returns the named connection with slaves called name, or nil.
+func (m *Manager) cluster(name string) *cluster {
 	m.connMu.RLock()
 	defer m.connMu.RUnlock()
+	return m.clusters[name]
+}
 
-	if conn, exists := m.connections[name]; exists {
-		return conn
+// storeConnection installs an open named connection and returns the
+// connection and cluster it replaces, if any. Once the manager is closed the
+// connection is closed instead and ErrManagerClosed is returned.
+func (m *Manager) storeConnection(name string, db *gorm.DB, c *cluster) (*gorm.DB, *cluster, error) {
+	m.connMu.Lock()
+	// Shutdown collects the pools to close under connMu after setting closed,
+	// so a connection stored here is always closed by one of the two
+	if m.closed.Load() {
+		m.connMu.Unlock()
+		if err := closeNamed(db, c); err != nil {
+			m.logWarn("Failed to close connection opened during shutdown", "name", name, "error", err)
+		}
+		return nil, nil, ErrManagerClosed
 	}
+	defer m.connMu.Unlock()
 
-	m.logWarn("Connection not found, using default", "name", name)
-	return m.db
+	previous, previousCluster := m.connections[name], m.clusters[name]
+	m.connections[name] = db
+	if c != nil {
+		m.clusters[name] = c
+	} else {
+		delete(m.clusters, name)
+	}
+	delete(m.connInit, name)
+	return previous, previousCluster, nil
+}
+
+// closeNamed closes a named connection, draining its slaves first since
+// they fall back to the master.
+func closeNamed(db *gorm.DB, c *cluster) error {
+	var errs []error
+	if c != nil {
+		errs = append(errs, c.close())
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		errs = append(errs, sqlDB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// missingDB returns a connection on which every operation fails with err,
+// handed out by Connection for unknown names with StrictConnections.
+func (m *Manager) missingDB(err error) *gorm.DB {
+	notFound := ErrConnectionNotFound
+	m.missingInit.lastErr.CompareAndSwap(nil, &notFound)
+
+	db := m.missingInit.failedDB().Session(&gorm.Session{NewDB: true})
+	_ = db.AddError(err)
+	return db
 }
 
 // HasConnection checks if a named connection exists.
+// In lazy mode this includes configured connections that are not open yet.
 func (m *Manager) HasConnection(name string) bool {
 	m.connMu.RLock()
 	defer m.connMu.RUnlock()
 	_, exists := m.connections[name]
-	return exists
+	_, pending := m.connInit[name]
+	return exists || pending
 }
 
 // AddConnection adds a new named connection at runtime.
+// Unset settings are inherited from the main config like configured connections.
 func (m *Manager) AddConnection(name string, config ConnectionConfig) error {
-	db, err := connectWithConfig(config, m.logger)
+	if m.closed.Load() {
+		return ErrManagerClosed
+	}
+
+	db, c, err := m.connectNamed(context.Background(), name, config)
 	if err != nil {
 		return fmt.Errorf("failed to add connection %s: %w", name, err)
 	}
 
-	m.connMu.Lock()
-	m.connections[name] = db
-	m.connMu.Unlock()
+	if _, _, err := m.storeConnection(name, db, c); err != nil {
+		return err
+	}
 
 	m.logInfo("Connection added", "name", name)
 	return nil
 }
 
-// RemoveConnection removes a named connection.
+// RemoveConnection removes a named connection. The slaves of a named
+// connection are closed once the reads routed to them have finished.
 func (m *Manager) RemoveConnection(name string) error {
+	// Detach the connection under the lock; waiting for reads and closing the
+	// pools happens without it so other connections stay usable meanwhile
 	m.connMu.Lock()
-	defer m.connMu.Unlock()
+	init, pending := m.connInit[name]
+	conn, exists := m.connections[name]
+	c := m.clusters[name]
+	delete(m.connInit, name)
+	delete(m.connections, name)
+	delete(m.clusters, name)
+	m.connMu.Unlock()
 
-	if conn, exists := m.connections[name]; exists {
+	switch {
+	case exists:
+		// Slaves fall back to the master, so they are drained first
+		if c != nil {
+			if err := c.close(); err != nil {
+				m.logWarn("Failed to close connection during removal", "name", name, "error", err)
+			}
+		}
 		if sqlDB, err := conn.DB(); err == nil {
 			if err := sqlDB.Close(); err != nil {
 				m.logWarn("Failed to close connection during removal", "name", name, "error", err)
 			}
 		}
-		delete(m.connections, name)
-		m.logInfo("Connection removed", "name", name)
-		return nil
+	case pending:
+		init.mu.Lock()
+		init.close()
+		init.mu.Unlock()
+	default:
+		return fmt.Errorf("%w: %s", ErrConnectionNotFound, name)
 	}
 
-	return fmt.Errorf("connection not found: %s", name)
+	m.logInfo("Connection removed", "name", name)
+	return nil
 }
 
 // ========== Common Methods ==========
 
 // Transaction runs a function within a transaction.
 func (m *Manager) Transaction(fn func(*gorm.DB) error) error {
-	return m.master.Transaction(fn)
+	return m.writer().Transaction(fn)
 }
 
 // AutoMigrate runs auto migration for given models.
 func (m *Manager) AutoMigrate(models ...interface{}) error {
+	if err := m.ensurePrimary(); err != nil {
+		return err
+	}
+	return migrateOnMaster(m.master, models...)
+}
+
+// migrateOnMaster runs auto migration on the given master connection.
+func migrateOnMaster(master *gorm.DB, models ...interface{}) error {
 	// Disable routing for migration to ensure it runs on master
 	// and doesn't get confused by read/write splitting
-	ctx := context.WithValue(context.Background(), skipRoutingKey, true)
-	db := m.master.Session(&gorm.Session{
-		Context: ctx,
+	db := master.Session(&gorm.Session{
+		Context: SkipRouting(context.Background()),
 	})
 
 	return db.AutoMigrate(models...)
@@ -400,12 +796,16 @@ type PoolStats struct {
 
 // Stats returns connection pool statistics for the primary database connection.
 func (m *Manager) Stats() PoolStats {
-	sqlDB, err := m.db.DB()
+	sqlDB, err := m.primary().DB()
 	if err != nil {
 		return PoolStats{}
 	}
 
-	stats := sqlDB.Stats()
+	return poolStats(sqlDB.Stats())
+}
+
+// poolStats converts database/sql pool statistics.
+func poolStats(stats sql.DBStats) PoolStats {
 	return PoolStats{
 		OpenConnections:   stats.OpenConnections,
 		InUse:             stats.InUse,
@@ -432,16 +832,7 @@ func (m *Manager) ConnectionStats(name string) PoolStats {
 		return PoolStats{}
 	}
 
-	stats := sqlDB.Stats()
-	return PoolStats{
-		OpenConnections:   stats.OpenConnections,
-		InUse:             stats.InUse,
-		Idle:              stats.Idle,
-		WaitCount:         stats.WaitCount,
-		WaitDuration:      stats.WaitDuration,
-		MaxIdleClosed:     stats.MaxIdleClosed,
-		MaxLifetimeClosed: stats.MaxLifetimeClosed,
-	}
+	return poolStats(sqlDB.Stats())
 }
 
 // AllStats returns connection pool statistics for all connections.
@@ -453,35 +844,18 @@ func (m *Manager) AllStats() map[string]PoolStats {
 
 	// Master/Slave connections (if read/write splitting enabled)
 	if m.config.ReadWriteSplitting {
+		m.ensureSlaves()
 		if m.master != nil {
 			sqlDB, err := m.master.DB()
 			if err == nil {
-				stats := sqlDB.Stats()
-				result["master"] = PoolStats{
-					OpenConnections:   stats.OpenConnections,
-					InUse:             stats.InUse,
-					Idle:              stats.Idle,
-					WaitCount:         stats.WaitCount,
-					WaitDuration:      stats.WaitDuration,
-					MaxIdleClosed:     stats.MaxIdleClosed,
-					MaxLifetimeClosed: stats.MaxLifetimeClosed,
-				}
+				result["master"] = poolStats(sqlDB.Stats())
 			}
 		}
 
-		for i, slave := range m.slaves {
-			sqlDB, err := slave.DB()
+		for _, r := range m.connectedReplicas() {
+			sqlDB, err := r.db.DB()
 			if err == nil {
-				stats := sqlDB.Stats()
-				result[fmt.Sprintf("slave_%d", i)] = PoolStats{
-					OpenConnections:   stats.OpenConnections,
-					InUse:             stats.InUse,
-					Idle:              stats.Idle,
-					WaitCount:         stats.WaitCount,
-					WaitDuration:      stats.WaitDuration,
-					MaxIdleClosed:     stats.MaxIdleClosed,
-					MaxLifetimeClosed: stats.MaxLifetimeClosed,
-				}
+				result[r.name] = poolStats(sqlDB.Stats())
 			}
 		}
 	}
@@ -493,6 +867,15 @@ func (m *Manager) AllStats() map[string]PoolStats {
 	}
 	m.connMu.RUnlock()
 
+	// Slaves of named connections, as <connection>/<slave>
+	for _, c := range m.clusterSnapshot() {
+		for _, r := range c.connectedReplicas() {
+			if sqlDB, err := r.db.DB(); err == nil {
+				result[c.replicaKey(r.name)] = poolStats(sqlDB.Stats())
+			}
+		}
+	}
+
 	return result
 }
 
@@ -502,16 +885,16 @@ func (m *Manager) HealthCheck() map[string]bool {
 	health := make(map[string]bool)
 
 	// Check primary
-	health["primary"] = m.ping(m.db) == nil
+	health["primary"] = m.ping(m.primary()) == nil
 
 	// Check master
 	if m.config.ReadWriteSplitting {
-		health["master"] = m.ping(m.master) == nil
+		m.ensureSlaves()
+		health["master"] = m.ping(m.writer()) == nil
 
-		// Check slaves
-		for i, slave := range m.slaves {
-			key := fmt.Sprintf("slave_%d", i)
-			health[key] = m.ping(slave) == nil
+		// Check slaves; slaves that failed to connect are unhealthy
+		for _, r := range m.replicaSnapshot() {
+			health[r.name] = r.db != nil && m.ping(r.db) == nil
 		}
 	}
 
@@ -522,6 +905,13 @@ func (m *Manager) HealthCheck() map[string]bool {
 	}
 	m.connMu.RUnlock()
 
+	// Check the slaves of named connections, as <connection>/<slave>
+	for _, c := range m.clusterSnapshot() {
+		for _, r := range c.replicaSnapshot() {
+			health[c.replicaKey(r.name)] = r.db != nil && m.ping(r.db) == nil
+		}
+	}
+
 	return health
 }
 
@@ -530,6 +920,9 @@ func (m *Manager) HealthCheck() map[string]bool {
 // SQL returns the underlying *sql.DB for the primary connection.
 // This is useful for integrating with tools like go-migrate that require *sql.DB.
 func (m *Manager) SQL() (*sql.DB, error) {
+	if err := m.ensurePrimary(); err != nil {
+		return nil, err
+	}
 	return m.master.DB()
 }
 
@@ -3,9 +3,13 @@ package database
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
@@ -243,6 +247,45 @@ func TestManager_MultiConnection(t *testing.T) {
 	}
 }
 
+func TestManager_NamedConnectionInheritsSettings(t *testing.T) {
+	config := Config{
+		Driver:          "sqlite",
+		FilePath:        ":memory:",
+		MaxOpenConns:    7,
+		MaxIdleConns:    3,
+		ConnMaxIdleTime: time.Minute,
+		SlowQuery:       SlowQueryConfig{Enabled: true, Threshold: time.Second},
+		Connections: map[string]ConnectionConfig{
+			"reports": {
+				FilePath: filepath.Join(t.TempDir(), "reports.db"),
+			},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer manager.Close()
+
+	reports := manager.Connection("reports")
+	if reports == manager.db {
+		t.Fatal("Expected 'reports' connection to be established")
+	}
+
+	sqlDB, err := reports.DB()
+	if err != nil {
+		t.Fatalf("Failed to get SQL DB: %v", err)
+	}
+	if maxOpen := sqlDB.Stats().MaxOpenConnections; maxOpen != 7 {
+		t.Errorf("Expected inherited MaxOpenConns 7, got %d", maxOpen)
+	}
+
+	if reports.Callback().Query().Get("dgcore:slow_query") == nil {
+		t.Error("Expected slow query plugin on named connection")
+	}
+}
+
 func TestManager_AddRemoveConnection(t *testing.T) {
 	config := Config{
 		Driver:   "sqlite",
@@ -405,3 +448,128 @@ func TestManager_ConcurrentTransactions(t *testing.T) {
 		t.Errorf("Expected %d users, got %d", workers, count)
 	}
 }
+
+// newConnectionManager creates a manager with a "reports" named connection.
+func newConnectionManager(t *testing.T, configure func(*Config)) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:   "sqlite",
+		FilePath: filepath.Join(dir, "primary.db"),
+		Connections: map[string]ConnectionConfig{
+			"reports": {FilePath: filepath.Join(dir, "reports.db")},
+		},
+	}
+	if configure != nil {
+		configure(&config)
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager
+}
+
+// TestManager_ConnectionE tests that ConnectionE returns errors instead of falling back
+func TestManager_ConnectionE(t *testing.T) {
+	manager := newConnectionManager(t, nil)
+
+	db, err := manager.ConnectionE("reports")
+	require.NoError(t, err)
+	assert.Same(t, manager.Connection("reports"), db)
+
+	db, err = manager.ConnectionE("analytics")
+	assert.Nil(t, db)
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	assert.EqualError(t, err, "connection not found: analytics")
+
+	// Connection keeps falling back to the primary connection
+	assert.Same(t, manager.db, manager.Connection("analytics"))
+
+	require.NoError(t, manager.Close())
+	_, err = manager.ConnectionE("reports")
+	assert.ErrorIs(t, err, ErrManagerClosed)
+}
+
+// TestManager_ConnectionE_Lazy tests that ConnectionE opens lazy connections and returns their errors
+func TestManager_ConnectionE_Lazy(t *testing.T) {
+	registerUnavailableDriver(t, "test-connection-unavailable")
+	manager := newConnectionManager(t, func(c *Config) {
+		c.Lazy = true
+		c.Connections["broken"] = ConnectionConfig{Driver: "test-connection-unavailable"}
+	})
+
+	db, err := manager.ConnectionE("reports")
+	require.NoError(t, err)
+	assert.NoError(t, db.Exec("SELECT 1").Error)
+
+	db, err = manager.ConnectionE("broken")
+	assert.Nil(t, db)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to named connection broken")
+	assert.NotErrorIs(t, err, ErrConnectionNotFound)
+}
+
+// TestManager_StrictConnections tests that unknown connections fail instead of falling back
+func TestManager_StrictConnections(t *testing.T) {
+	logger := &mockSlowQueryLogger{}
+	manager := newConnectionManager(t, func(c *Config) { c.StrictConnections = true })
+	manager.logger = logger
+
+	assert.NoError(t, manager.Connection("reports").Exec("SELECT 1").Error)
+
+	db := manager.Connection("analytics")
+	assert.NotSame(t, manager.db, db)
+	err := db.Exec("SELECT 1").Error
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	assert.EqualError(t, err, "connection not found: analytics")
+	assert.ErrorIs(t, db.Raw("SELECT 1").Scan(&struct{}{}).Error, ErrConnectionNotFound)
+	assert.Contains(t, logger.warnings, "Connection not found")
+
+	// Each call reports its own name
+	assert.EqualError(t, manager.Connection("billing").Exec("SELECT 1").Error, "connection not found: billing")
+}
+
+// TestManager_DB_DefaultConnection tests that DB returns the connection named by DefaultConnection
+func TestManager_DB_DefaultConnection(t *testing.T) {
+	manager := newConnectionManager(t, func(c *Config) { c.DefaultConnection = "reports" })
+
+	assert.Same(t, manager.Connection("reports"), manager.DB())
+	require.NoError(t, manager.DB().Exec("CREATE TABLE reports (id INTEGER)").Error)
+
+	var count int64
+	require.NoError(t, manager.Connection("reports").Raw("SELECT COUNT(*) FROM reports").Scan(&count).Error)
+
+	// The primary connection is untouched
+	assert.Error(t, manager.Write().Raw("SELECT COUNT(*) FROM reports").Scan(&count).Error)
+
+	for _, name := range []string{"", "default", "primary"} {
+		manager := newConnectionManager(t, func(c *Config) { c.DefaultConnection = name })
+		assert.Same(t, manager.db, manager.DB(), name)
+	}
+}
+
+// TestManager_DB_DefaultConnectionRequired tests that NewManager fails when the default connection cannot connect
+func TestManager_DB_DefaultConnectionRequired(t *testing.T) {
+	registerUnavailableDriver(t, "test-default-unavailable")
+
+	config := Config{
+		Driver:            "sqlite",
+		FilePath:          ":memory:",
+		DefaultConnection: "reports",
+		Connections: map[string]ConnectionConfig{
+			"reports": {Driver: "test-default-unavailable"},
+		},
+	}
+
+	_, err := NewManager(config, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to named connection reports")
+
+	// Other named connections may still fail without stopping the manager
+	config.DefaultConnection = ""
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	_ = manager.Close()
+}
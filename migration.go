@@ -143,6 +143,9 @@ func (m *Migrator) recordMigration(id string) error {
 
 // Migrate runs migrations using the manager's primary connection.
 func (m *Manager) Migrate(migrations []Migration) error {
+	if err := m.ensurePrimary(); err != nil {
+		return err
+	}
 	migrator := NewMigrator(m.db)
 	for _, migration := range migrations {
 		migrator.Add(migration)
@@ -152,6 +155,9 @@ func (m *Manager) Migrate(migrations []Migration) error {
 
 // Rollback rolls back the last migration.
 func (m *Manager) Rollback(migrations []Migration) error {
+	if err := m.ensurePrimary(); err != nil {
+		return err
+	}
 	migrator := NewMigrator(m.master)
 	for _, migration := range migrations {
 		migrator.Add(migration)
@@ -161,6 +167,9 @@ func (m *Manager) Rollback(migrations []Migration) error {
 
 // MigrationStatus returns the status of migrations.
 func (m *Manager) MigrationStatus() ([]string, error) {
+	if err := m.ensurePrimary(); err != nil {
+		return nil, err
+	}
 	migrator := NewMigrator(m.db)
 	return migrator.Status()
 }
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Health monitor defaults.
+const (
+	defaultMonitorInterval          = 10 * time.Second
+	defaultMonitorTimeout           = 5 * time.Second
+	defaultMonitorFailureThreshold  = 1
+	defaultMonitorRecoveryThreshold = 3
+)
+
+// slaveHealth is the health monitor state of a connected slave.
+type slaveHealth struct {
+	inRotation  bool // False while ejected by health checks
+	failures    int  // Consecutive failed checks
+	successes   int  // Consecutive successful checks
+	latency     time.Duration
+	lastChecked time.Time
+	lastErr     error
+
+	stale bool // Replication lag exceeds MaxReplicationLag
+	lag   time.Duration
+
+	ewma time.Duration // Moving average of query and health check latency
+}
+
+// available reports whether reads may be routed to the slave.
+func (h *slaveHealth) available() bool {
+	return h.inRotation && !h.stale
+}
+
+// observe adds a latency sample to the moving average. The first sample
+// replaces the average.
+func (h *slaveHealth) observe(latency time.Duration) {
+	if h.ewma == 0 {
+		h.ewma = latency
+		return
+	}
+	h.ewma += time.Duration(ewmaDecay * float64(latency-h.ewma))
+}
+
+// healthMonitor runs the background slave health checks.
+type healthMonitor struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Topology is a snapshot of the read/write topology and the routing state of each slave.
+type Topology struct {
+	Master string        // Master host, or the primary host when no master is configured
+	Slaves []SlaveStatus // Configured slaves, including those that failed to connect
+}
+
+// SlaveStatus describes a slave connection and its health monitor state.
+type SlaveStatus struct {
+	Name                 string            // Replica name used in stats, health checks and Slave
+	Host                 string            // Slave host
+	Tags                 map[string]string // Configured tags
+	State                ReplicaState      // Connection state
+	InRotation           bool              // Whether reads are routed to the slave
+	Stale                bool              // Replication lag exceeds MaxReplicationLag
+	ReplicationLag       time.Duration     // Last measured replication lag
+	ConsecutiveFailures  int               // Failed checks since the last success
+	ConsecutiveSuccesses int               // Successful checks since the last failure
+	Latency              time.Duration     // Latency of the last check
+	LastChecked          time.Time         // Time of the last check, zero before the first one
+	LastError            error             // Error of the last failed check or connection attempt
+}
+
+// Topology returns the current read/write topology. Slaves ejected by the
+// health monitor or over MaxReplicationLag are reported with InRotation set to false.
+func (m *Manager) Topology() Topology {
+	topology := Topology{Master: m.config.Host}
+	if m.config.Master.Host != "" {
+		topology.Master = m.config.Master.Host
+	}
+
+	for _, r := range m.replicaSnapshot() {
+		status := SlaveStatus{
+			Name:                 r.name,
+			Host:                 r.config.Host,
+			Tags:                 r.config.Tags,
+			State:                r.state,
+			InRotation:           r.routable(),
+			Stale:                r.stale,
+			ReplicationLag:       r.lag,
+			ConsecutiveFailures:  r.failures,
+			ConsecutiveSuccesses: r.successes,
+			Latency:              r.latency,
+			LastChecked:          r.lastChecked,
+			LastError:            r.lastErr,
+		}
+		if r.err != nil {
+			status.LastError = r.err
+		}
+		topology.Slaves = append(topology.Slaves, status)
+	}
+
+	return topology
+}
+
+// startHealthMonitor starts the background slave health checks.
+func (m *Manager) startHealthMonitor() {
+	interval := m.settings().monitorInterval()
+
+	m.monitor = &healthMonitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(m.monitor.done)
+
+		current := interval
+		ticker := time.NewTicker(current)
+		defer ticker.Stop()
+
+		// Check right away so stale slaves are skipped before the first tick
+		m.checkSlaves()
+
+		for {
+			select {
+			case <-m.monitor.stop:
+				return
+			case <-ticker.C:
+				m.checkSlaves()
+
+				// Reload may change the interval
+				if next := m.settings().monitorInterval(); next != current {
+					current = next
+					ticker.Reset(current)
+				}
+			}
+		}
+	}()
+
+	m.logInfo("Slave health monitor started", "interval", interval)
+}
+
+// monitorEnabled reports whether the config runs the background slave monitor.
+// The monitor also checks the slaves of named connections, which do not need
+// ReadWriteSplitting.
+func (c Config) monitorEnabled() bool {
+	return c.HealthMonitor.Enabled || c.MaxReplicationLag > 0
+}
+
+// healthThresholds returns the health monitor failure and recovery thresholds.
+func (c Config) healthThresholds() (failure, recovery int) {
+	failure, recovery = c.HealthMonitor.FailureThreshold, c.HealthMonitor.RecoveryThreshold
+	if failure <= 0 {
+		failure = defaultMonitorFailureThreshold
+	}
+	if recovery <= 0 {
+		recovery = defaultMonitorRecoveryThreshold
+	}
+	return failure, recovery
+}
+
+// monitorInterval returns the time between slave checks.
+func (c Config) monitorInterval() time.Duration {
+	if c.HealthMonitor.Interval <= 0 {
+		return defaultMonitorInterval
+	}
+	return c.HealthMonitor.Interval
+}
+
+// stopHealthMonitor stops the health monitor and waits for a running check to finish.
+func (m *Manager) stopHealthMonitor() {
+	if m.monitor == nil {
+		return
+	}
+
+	m.monitor.stopOnce.Do(func() {
+		close(m.monitor.stop)
+	})
+	<-m.monitor.done
+}
+
+// checkSlaves runs one health check and, with MaxReplicationLag set, one lag
+// check on every connected slave, including the slaves of named connections.
+func (m *Manager) checkSlaves() {
+	// Reload may change the config while the checks run
+	settings := m.settings()
+	timeout := settings.HealthMonitor.Timeout
+	if timeout <= 0 {
+		timeout = defaultMonitorTimeout
+	}
+
+	for _, r := range m.connectedReplicas() {
+		health, lag, lagErr := m.checkSlave(settings, timeout, r, settings.replicationLagSource(r.config))
+		if health != nil {
+			m.recordSlaveHealth(r.name, *health)
+		}
+		if lag != nil {
+			m.recordReplicationLag(r.name, *lag, lagErr)
+		}
+	}
+
+	for _, c := range m.clusterSnapshot() {
+		for _, r := range c.connectedReplicas() {
+			lagSource := settings.lagSourceFor(c.slaveConfig(r).Driver)
+			health, lag, lagErr := m.checkSlave(settings, timeout, r, lagSource)
+			if health != nil {
+				m.recordClusterHealth(c, r.name, *health)
+			}
+			if lag != nil {
+				m.recordClusterLag(c, r.name, *lag, lagErr)
+			}
+		}
+	}
+}
+
+// checkSlave runs the checks enabled in settings on a slave. The health or lag
+// result is nil when that check did not run.
+func (m *Manager) checkSlave(settings Config, timeout time.Duration, r replica, lagSource ReplicationLagFunc) (*ConnectionHealth, *time.Duration, error) {
+	var health *ConnectionHealth
+	if settings.HealthMonitor.Enabled {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		h := m.checkConnectionHealthContext(ctx, r.db, r.name)
+		cancel()
+		health = &h
+	}
+
+	if settings.MaxReplicationLag <= 0 || lagSource == nil {
+		return health, nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	lag, err := lagSource(ctx, r.db)
+	cancel()
+	return health, &lag, err
+}
+
+// connectedReplica returns the connected registry entry with the given name,
+// or nil. slaveMu must be held.
+func (m *Manager) connectedReplica(name string) *replica {
+	for _, r := range m.replicas {
+		if r.name == name && r.connected() {
+			return r
+		}
+	}
+	return nil
+}
+
+// recordReplicationLag updates the lag state of a slave. A slave whose lag
+// cannot be measured is treated as stale.
+func (m *Manager) recordReplicationLag(name string, lag time.Duration, err error) {
+	maxLag := m.settings().MaxReplicationLag
+
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	if state := m.connectedReplica(name); state != nil {
+		m.applyReplicationLag(state, name, lag, err, maxLag)
+	}
+}
+
+// applyReplicationLag updates the lag state of a slave, logged as name. The
+// lock guarding the slave must be held.
+func (m *Manager) applyReplicationLag(state *replica, name string, lag time.Duration, err error, maxLag time.Duration) {
+	state.lag = lag
+
+	stale := err != nil || lag > maxLag
+	if stale == state.stale {
+		return
+	}
+	state.stale = stale
+
+	switch {
+	case err != nil:
+		m.logWarn("Failed to measure slave replication lag, skipping slave", "slave", name, "error", err)
+	case stale:
+		m.logWarn("Slave replication lag exceeds maximum, skipping slave", "slave", name, "lag", lag, "max_lag", maxLag)
+	default:
+		m.logInfo("Slave replication lag recovered", "slave", name, "lag", lag)
+	}
+}
+
+// recordSlaveHealth updates the state of a slave with a health check result,
+// ejecting or reinstating it when a threshold is reached.
+func (m *Manager) recordSlaveHealth(name string, health ConnectionHealth) {
+	failureThreshold, recoveryThreshold := m.settings().healthThresholds()
+
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	if state := m.connectedReplica(name); state != nil {
+		m.applySlaveHealth(state, name, health, failureThreshold, recoveryThreshold)
+	}
+}
+
+// applySlaveHealth updates the state of a slave, logged as name, with a health
+// check result. The lock guarding the slave must be held.
+func (m *Manager) applySlaveHealth(state *replica, name string, health ConnectionHealth, failureThreshold, recoveryThreshold int) {
+	state.latency = health.Latency
+	state.lastChecked = health.LastChecked
+
+	if health.Status == HealthStatusUnhealthy {
+		state.failures++
+		state.successes = 0
+		state.lastErr = health.Error
+
+		if state.inRotation && state.failures >= failureThreshold {
+			state.inRotation = false
+			m.logWarn("Slave ejected from read pool", "slave", name, "failures", state.failures, "error", health.Error)
+		}
+		return
+	}
+
+	state.successes++
+	state.failures = 0
+	state.observe(health.Latency)
+
+	if !state.inRotation && state.successes >= recoveryThreshold {
+		state.inRotation = true
+		state.lastErr = nil
+		m.logInfo("Slave reinstated into read pool", "slave", name, "successes", state.successes)
+	}
+}
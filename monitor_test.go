@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMonitoredManager creates a manager with two file-backed sqlite slaves.
+func newMonitoredManager(t *testing.T, monitor HealthMonitorConfig, logger Logger) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		SlaveStrategy:      "round-robin",
+		HealthMonitor:      monitor,
+		Slaves: []ConnectionConfig{
+			{Host: "replica-a", FilePath: filepath.Join(dir, "slave_a.db")},
+			{Host: "replica-b", FilePath: filepath.Join(dir, "slave_b.db")},
+		},
+	}
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager
+}
+
+// TestManager_HealthMonitor_EjectsAndReinstates tests the ejection and recovery thresholds
+func TestManager_HealthMonitor_EjectsAndReinstates(t *testing.T) {
+	logger := &mockSlowQueryLogger{}
+	manager := newMonitoredManager(t, HealthMonitorConfig{RecoveryThreshold: 2}, logger)
+
+	unhealthy := ConnectionHealth{Status: HealthStatusUnhealthy, Error: errors.New("connection refused"), LastChecked: time.Now()}
+	healthy := ConnectionHealth{Status: HealthStatusHealthy, LastChecked: time.Now()}
+
+	manager.recordSlaveHealth("slave_0", unhealthy)
+
+	topology := manager.Topology()
+	require.Len(t, topology.Slaves, 2)
+	assert.Equal(t, "replica-a", topology.Slaves[0].Host)
+	assert.False(t, topology.Slaves[0].InRotation)
+	assert.Equal(t, 1, topology.Slaves[0].ConsecutiveFailures)
+	assert.EqualError(t, topology.Slaves[0].LastError, "connection refused")
+	assert.True(t, topology.Slaves[1].InRotation)
+	assert.Contains(t, logger.warnings, "Slave ejected from read pool")
+
+	// Reads only go to the remaining slave
+	for i := 0; i < 4; i++ {
+		assert.Same(t, manager.replicas[1].db, manager.selectSlave())
+	}
+
+	// One success is not enough to reinstate
+	manager.recordSlaveHealth("slave_0", healthy)
+	assert.False(t, manager.Topology().Slaves[0].InRotation)
+
+	manager.recordSlaveHealth("slave_0", healthy)
+	topology = manager.Topology()
+	assert.True(t, topology.Slaves[0].InRotation)
+	assert.Nil(t, topology.Slaves[0].LastError)
+	assert.Contains(t, logger.infos, "Slave reinstated into read pool")
+}
+
+// TestManager_HealthMonitor_AllSlavesEjected tests that reads fall back to master
+func TestManager_HealthMonitor_AllSlavesEjected(t *testing.T) {
+	manager := newMonitoredManager(t, HealthMonitorConfig{}, nil)
+
+	unhealthy := ConnectionHealth{Status: HealthStatusUnhealthy, Error: errors.New("timeout")}
+	manager.recordSlaveHealth("slave_0", unhealthy)
+	manager.recordSlaveHealth("slave_1", unhealthy)
+
+	assert.Nil(t, manager.selectSlave())
+	assert.Same(t, manager.master, manager.Read())
+}
+
+// TestManager_HealthMonitor_Background tests that the monitor ejects a failed slave
+func TestManager_HealthMonitor_Background(t *testing.T) {
+	manager := newMonitoredManager(t, HealthMonitorConfig{
+		Enabled:  true,
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Second,
+	}, nil)
+
+	// Simulate a replica outage
+	sqlDB, err := manager.replicas[0].db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	require.Eventually(t, func() bool {
+		return !manager.Topology().Slaves[0].InRotation
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.True(t, manager.Topology().Slaves[1].InRotation)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, manager.Read().Exec("SELECT 1").Error)
+	}
+}
@@ -1,18 +1,44 @@
 package database
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// slaveReadKey is the statement key for the slave serving a routed read.
+const slaveReadKey = "dgcore:slave_read"
+
+// slaveRead records which slave a read was routed to and when it started.
+type slaveRead struct {
+	replica *replica
+	start   time.Time
+}
+
+// readWriteRouter is a master and the slaves ReadWritePlugin routes between:
+// the primary connection of a Manager or a named connection with slaves.
+type readWriteRouter interface {
+	// masterDB returns the master connection.
+	masterDB() *gorm.DB
+	// slaveByName returns a connected slave by replica name, or nil.
+	slaveByName(name string) *gorm.DB
+	// readSlave picks a slave for a read; see Manager.pickSlave.
+	readSlave() (*gorm.DB, *replica)
+	// observeSlaveLatency records the latency of a read served by a slave.
+	observeSlaveLatency(r *replica, latency time.Duration)
+}
+
 // ReadWritePlugin is a GORM plugin that automatically routes queries to master/slave.
 type ReadWritePlugin struct {
 	manager *Manager
+	router  readWriteRouter
 }
 
 // NewReadWritePlugin creates a new read/write routing plugin.
 func NewReadWritePlugin(manager *Manager) *ReadWritePlugin {
 	return &ReadWritePlugin{
 		manager: manager,
+		router:  manager,
 	}
 }
 
@@ -27,6 +53,7 @@ func (p *ReadWritePlugin) Initialize(db *gorm.DB) error {
 
 	// Query callback - use slave for reads
 	db.Callback().Query().Before("gorm:query").Register("dgcore:route_read", p.routeRead)
+	db.Callback().Query().After("gorm:query").Register("dgcore:observe_read", p.observeRead)
 
 	// Create callback - use master for writes
 	db.Callback().Create().Before("gorm:create").Register("dgcore:route_write", p.routeWrite)
@@ -37,21 +64,28 @@ func (p *ReadWritePlugin) Initialize(db *gorm.DB) error {
 	// Delete callback - use master for writes
 	db.Callback().Delete().Before("gorm:delete").Register("dgcore:route_write", p.routeWrite)
 
-	// Raw callback - use master for raw SQL
-	db.Callback().Raw().Before("gorm:raw").Register("dgcore:route_write", p.routeWrite)
+	// Raw (Exec) and Row (Raw().Scan, Row, Rows) callbacks - classify the SQL
+	db.Callback().Raw().Before("gorm:raw").Register("dgcore:route_read", p.routeRead)
+	db.Callback().Raw().After("gorm:raw").Register("dgcore:observe_read", p.observeRead)
+	db.Callback().Row().Before("gorm:row").Register("dgcore:route_read", p.routeRead)
+	db.Callback().Row().After("gorm:row").Register("dgcore:observe_read", p.observeRead)
 
 	return nil
 }
 
-// routeRead routes read queries to slave connections.
+// routeRead routes read queries to slave connections. Raw SQL that writes,
+// locks rows or uses session state is routed like a write.
 func (p *ReadWritePlugin) routeRead(db *gorm.DB) {
-	// Skip if routing is disabled via context
-	if v, ok := db.Statement.Context.Value(skipRoutingKey).(bool); ok && v {
+	ctx := db.Statement.Context
+
+	// Writes, locking reads and data-modifying CTEs go to master
+	if isWriteOperation(db) {
+		p.routeWrite(db)
 		return
 	}
 
-	// Skip if explicitly a write operation
-	if isWriteOperation(db) {
+	// Skip if routing is disabled via context
+	if routingSkipped(ctx) {
 		return
 	}
 
@@ -60,6 +94,21 @@ func (p *ReadWritePlugin) routeRead(db *gorm.DB) {
 		return
 	}
 
+	// Explicit hints from UseMaster and UseReplica
+	if masterRequested(ctx) {
+		p.routeMaster(db)
+		return
+	}
+	if name, ok := requestedReplica(ctx); ok {
+		if slave := p.router.slaveByName(name); slave != nil {
+			db.Statement.ConnPool = slave.Statement.ConnPool
+			return
+		}
+		p.manager.logWarn("Requested replica not available, using master", "replica", name)
+		p.routeMaster(db)
+		return
+	}
+
 	// Skip if query involves system tables (schema checks should go to master)
 	sql := db.Statement.SQL.String()
 
@@ -78,20 +127,50 @@ func (p *ReadWritePlugin) routeRead(db *gorm.DB) {
 		}
 	}
 
-	// Use slave for reads
-	if len(p.manager.slaves) > 0 {
-		slave := p.manager.selectSlave()
+	// Read-your-writes: stay on master shortly after a write in a sticky session
+	if session := stickySessionFrom(ctx); session != nil && session.pinned(p.manager.stickyWindow()) {
+		p.routeMaster(db)
+		return
+	}
+
+	// Use slave for reads (connecting slaves on first use in lazy mode)
+	if slave, r := p.router.readSlave(); slave != nil {
 		db.Statement.ConnPool = slave.Statement.ConnPool
+		db.InstanceSet(slaveReadKey, slaveRead{replica: r, start: time.Now()})
+	}
+}
+
+// observeRead marks a read routed to a slave as finished and feeds its latency
+// into the slave's moving average for latency-aware selectors. For Rows and Raw
+// this runs before the rows are iterated; their connection stays in use until
+// they are closed, which closing a replica waits for separately.
+func (p *ReadWritePlugin) observeRead(db *gorm.DB) {
+	v, _ := db.InstanceGet(slaveReadKey)
+	read, ok := v.(slaveRead)
+	if !ok {
+		return
+	}
+	// A reused statement must not report a later master read for this slave
+	db.InstanceSet(slaveReadKey, nil)
+	read.replica.reads.Done()
+
+	if db.Error == nil {
+		p.router.observeSlaveLatency(read.replica, time.Since(read.start))
 	}
 }
 
 // routeWrite routes write queries to master connection.
 func (p *ReadWritePlugin) routeWrite(db *gorm.DB) {
 	// Skip if routing is disabled via context
-	if v, ok := db.Statement.Context.Value(skipRoutingKey).(bool); ok && v {
+	if routingSkipped(db.Statement.Context) {
 		return
 	}
 
+	// Remember the write so later reads in a sticky session see it
+	if session := stickySessionFrom(db.Statement.Context); session != nil {
+		session.recordWrite()
+	}
+
 	// Skip if in transaction (ConnPool is *sql.Tx)
 	// If we are in a transaction, we assume we are already on the correct connection (Master)
 	// Changing ConnPool here would break the transaction
@@ -102,27 +181,32 @@ func (p *ReadWritePlugin) routeWrite(db *gorm.DB) {
 	// Always force master for writes
 	// This ensures that if a previous read operation in the same session
 	// switched the ConnPool to a slave, we switch it back to master.
-	if p.manager.master != nil {
-		db.Statement.ConnPool = p.manager.master.Statement.ConnPool
+	p.routeMaster(db)
+}
+
+// routeMaster sends the statement to the master connection.
+func (p *ReadWritePlugin) routeMaster(db *gorm.DB) {
+	if master := p.router.masterDB(); master != nil {
+		db.Statement.ConnPool = master.Statement.ConnPool
 	}
 }
 
-// isWriteOperation checks if the operation is a write.
+// isWriteOperation checks if the statement must run on master. Statements
+// without SQL yet, such as Find before GORM builds the query, are reads
+// unless they lock rows with clause.Locking.
 func isWriteOperation(db *gorm.DB) bool {
-	// Check for write operations
+	if _, locking := db.Statement.Clauses["FOR"]; locking {
+		return true
+	}
+
 	sql := db.Statement.SQL.String()
 	if sql == "" {
 		return false
 	}
 
-	// Simple check for write operations
-	// In production, you might want a more sophisticated check
-	writeKeywords := []string{"INSERT", "UPDATE", "DELETE", "CREATE", "ALTER", "DROP"}
-	for _, keyword := range writeKeywords {
-		if len(sql) >= len(keyword) && sql[:len(keyword)] == keyword {
-			return true
-		}
+	dialect := ""
+	if db.Config != nil && db.Dialector != nil {
+		dialect = db.Dialector.Name()
 	}
-
-	return false
+	return !isReadOnlySQL(dialect, sql)
 }
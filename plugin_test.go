@@ -1,12 +1,14 @@
 package database
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TestReadWritePlugin_Metadata tests the plugin metadata
@@ -158,7 +160,7 @@ func TestReadWritePlugin_LoadBalancing(t *testing.T) {
 		defer manager.Close()
 
 		// Verify we have 3 slaves
-		assert.Len(t, manager.slaves, 3, "Should have 3 slaves")
+		assert.Len(t, manager.connectedReplicas(), 3, "Should have 3 slaves")
 
 		// Call selectSlave multiple times and verify round-robin
 		// Note: We can't easily verify the exact slave selected without
@@ -273,6 +275,23 @@ func TestIsWriteOperation(t *testing.T) {
 	})
 }
 
+// TestReadWritePlugin_LockingReadRouting tests that builder locking reads go to master
+func TestReadWritePlugin_LockingReadRouting(t *testing.T) {
+	manager := newHintManager(t)
+	db := manager.DB()
+
+	for i := 0; i < 4; i++ {
+		var item stickyItem
+		require.NoError(t, db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item).Error)
+		assert.Equal(t, "master", item.Name)
+	}
+
+	// Plain reads still go to slaves
+	source, err := findSource(context.Background(), db)
+	require.NoError(t, err)
+	assert.NotEqual(t, "master", source)
+}
+
 // TestReadWritePlugin_AutoRouting_ActualQueries tests routing with actual queries
 func TestReadWritePlugin_AutoRouting_ActualQueries(t *testing.T) {
 	config := Config{
@@ -307,8 +326,8 @@ func TestReadWritePlugin_AutoRouting_ActualQueries(t *testing.T) {
 	require.NoError(t, err)
 
 	// Migrate on slaves
-	for i := range manager.slaves {
-		err = manager.Slave(i).AutoMigrate(&TestModel{})
+	for _, name := range manager.ReplicaNames() {
+		err = manager.Slave(name).AutoMigrate(&TestModel{})
 		require.NoError(t, err)
 	}
 
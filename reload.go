@@ -0,0 +1,486 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// liveSettings holds the settings of a Manager that Reload changes on open
+// connections: the GORM logger and the slow query configuration.
+type liveSettings struct {
+	logger        reloadableLogger
+	slowQueryConf atomic.Pointer[SlowQueryConfig]
+}
+
+func newLiveSettings(config Config) *liveSettings {
+	s := &liveSettings{logger: reloadableLogger{writer: log.New(os.Stdout, "\r\n", log.LstdFlags)}}
+	s.update(config)
+	return s
+}
+
+// update applies the logging settings of config.
+func (s *liveSettings) update(config Config) {
+	s.logger.set(config.LogLevel, config.SlowThreshold)
+	slowQuery := config.SlowQuery
+	s.slowQueryConf.Store(&slowQuery)
+}
+
+// slowQuery returns the current slow query configuration.
+func (s *liveSettings) slowQuery() SlowQueryConfig {
+	return *s.slowQueryConf.Load()
+}
+
+// reloadableLogger is a GORM logger that forwards to the logger built from the
+// current LogLevel and SlowThreshold.
+type reloadableLogger struct {
+	writer  logger.Writer
+	current atomic.Pointer[logger.Interface]
+}
+
+func (l *reloadableLogger) set(logLevel string, slowThreshold time.Duration) {
+	next := newGORMLogger(callerWriter{l.writer}, logLevel, slowThreshold)
+	l.current.Store(&next)
+}
+
+func (l *reloadableLogger) get() logger.Interface {
+	return *l.current.Load()
+}
+
+// LogMode implements logger.Interface. The returned logger keeps the level
+// and no longer follows Reload, like a Debug session in GORM.
+func (l *reloadableLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return l.get().LogMode(level)
+}
+
+// Info implements logger.Interface.
+func (l *reloadableLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.get().Info(ctx, msg, data...)
+}
+
+// Warn implements logger.Interface.
+func (l *reloadableLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.get().Warn(ctx, msg, data...)
+}
+
+// Error implements logger.Interface.
+func (l *reloadableLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.get().Error(ctx, msg, data...)
+}
+
+// Trace implements logger.Interface.
+func (l *reloadableLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.get().Trace(ctx, begin, fc, err)
+}
+
+// callerWriter corrects the file:line that GORM loggers print first on every
+// line. GORM reports the first caller outside GORM, which behind a
+// reloadableLogger is the reloadableLogger itself.
+type callerWriter struct {
+	logger.Writer
+}
+
+func (w callerWriter) Printf(format string, args ...interface{}) {
+	if len(args) > 0 {
+		args[0] = logCaller()
+	}
+	w.Writer.Printf(format, args...)
+}
+
+// logCaller returns the file:line of the code that ran the logged query,
+// skipping GORM and query logging frames.
+func logCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !queryLoggingFrame(frame.Function) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// queryLoggingFrame reports whether function belongs to GORM or to the code
+// logging a query rather than to the code that ran it.
+func queryLoggingFrame(function string) bool {
+	return strings.HasPrefix(function, "gorm.io/") ||
+		strings.Contains(function, ".(*reloadableLogger).") ||
+		strings.Contains(function, ".(*SlowQueryPlugin).")
+}
+
+// Reload applies a new configuration to the running Manager without a restart.
+// It diffs newConfig against the running topology and:
+//   - adds, replaces and removes named connections and slaves; slaves are
+//     matched by replica name,
+//   - changes the pool limits of open connections,
+//   - changes LogLevel, SlowThreshold and SlowQuery on open connections,
+//   - swaps the replica selector when SlaveStrategy or ReplicaSelector changed,
+//   - changes HealthMonitor, MaxReplicationLag and ReplicationLag for the next
+//     slave checks, StickyWindow for the next reads and Retry for the next
+//     connections opened,
+//   - changes DefaultConnection, StrictConnections and Shards after the named
+//     connections are updated.
+//
+// A connection whose server, database or options changed is replaced: the new
+// pool is opened first, new queries use it, and the old pool is closed once
+// the queries running on it have finished. Reload returns after the old pools
+// are closed. A *gorm.DB obtained from a replaced pool stops working, so fetch
+// connections from the Manager per request instead of keeping them.
+//
+// The primary and master connections, ReadWriteSplitting, AutoRouting and Lazy
+// cannot be reloaded, nor can the health monitor be started or stopped by
+// enabling or disabling both HealthMonitor and MaxReplicationLag; Reload
+// returns an error without applying anything when they change. A new
+// CredentialsProvider only applies to pools Reload opens.
+//
+// A named connection with slaves is replaced as a whole when its slaves change;
+// pool limits of its slaves and its SlaveStrategy change in place.
+//
+// Changes that fail, such as a new slave that cannot connect, are logged and
+// returned together; the rest of the configuration is still applied and a
+// connection that could not be replaced keeps its old pool. Concurrent calls
+// are applied one after another.
+func (m *Manager) Reload(newConfig Config) error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if m.closed.Load() {
+		return ErrManagerClosed
+	}
+	if err := newConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	current := m.settings()
+	if err := checkReloadable(current, newConfig); err != nil {
+		return err
+	}
+
+	var selector ReplicaSelector
+	if newConfig.ReplicaSelector != nil {
+		selector = newConfig.ReplicaSelector
+	} else if current.ReplicaSelector != nil || newConfig.SlaveStrategy != current.SlaveStrategy {
+		// The strategy name was checked by Validate
+		selector, _ = newReplicaSelector(newConfig.SlaveStrategy)
+	}
+
+	// Pool limits are applied to the primary under its lazy initialization
+	// lock, so a concurrent lazy connect either sees the new config or is
+	// updated here
+	m.primaryInit.mu.Lock()
+	m.configMu.Lock()
+	m.config.MaxOpenConns = newConfig.MaxOpenConns
+	m.config.MaxIdleConns = newConfig.MaxIdleConns
+	m.config.ConnMaxLifetime = newConfig.ConnMaxLifetime
+	m.config.ConnMaxIdleTime = newConfig.ConnMaxIdleTime
+	m.config.LogLevel = newConfig.LogLevel
+	m.config.SlowThreshold = newConfig.SlowThreshold
+	m.config.SlowQuery = newConfig.SlowQuery
+	m.config.SlaveStrategy = newConfig.SlaveStrategy
+	m.config.ReplicaSelector = newConfig.ReplicaSelector
+	m.config.Slaves = slices.Clone(newConfig.Slaves)
+	m.config.Connections = maps.Clone(newConfig.Connections)
+	m.config.HealthMonitor = newConfig.HealthMonitor
+	m.config.MaxReplicationLag = newConfig.MaxReplicationLag
+	m.config.ReplicationLag = newConfig.ReplicationLag
+	m.config.StickyWindow = newConfig.StickyWindow
+	m.config.Retry = newConfig.Retry
+	next := m.config
+	m.configMu.Unlock()
+
+	m.config.live.update(next)
+	if m.primaryInit.done.Load() {
+		m.reloadPool(m.db, next.primaryConnection())
+		if m.master != m.db {
+			m.reloadPool(m.master, next.inherit(next.Master))
+		}
+	}
+	m.primaryInit.mu.Unlock()
+
+	var errs []error
+	if next.ReadWriteSplitting {
+		if err := m.reloadSlaves(current, next, selector); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := m.reloadConnections(current, next); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Switch the default connection once the new connections are open
+	m.configMu.Lock()
+	m.config.DefaultConnection = newConfig.DefaultConnection
+	m.config.StrictConnections = newConfig.StrictConnections
+	m.config.Shards = maps.Clone(newConfig.Shards)
+	m.configMu.Unlock()
+
+	m.logInfo("Configuration reloaded")
+	return errors.Join(errs...)
+}
+
+// checkReloadable returns an error when next changes settings that Reload
+// cannot apply.
+func checkReloadable(current, next Config) error {
+	var changed string
+	switch {
+	case !sameEndpoint(current.primaryConnection(), next.primaryConnection()):
+		changed = "primary connection"
+	case current.ReadWriteSplitting != next.ReadWriteSplitting:
+		changed = "ReadWriteSplitting"
+	case current.ReadWriteSplitting && !sameEndpoint(current.inherit(current.Master), next.inherit(next.Master)):
+		changed = "master connection"
+	case current.AutoRouting != next.AutoRouting:
+		changed = "AutoRouting"
+	case current.Lazy != next.Lazy:
+		changed = "Lazy"
+	case current.monitorEnabled() != next.monitorEnabled():
+		changed = "health monitor"
+	default:
+		return nil
+	}
+	return fmt.Errorf("cannot reload configuration: %s changed, restart required", changed)
+}
+
+// sameEndpoint reports whether two resolved connection configs connect to the
+// same server and database with the same options, so an open pool can be kept.
+// Pool limits, replica settings and the credentials provider are ignored.
+func sameEndpoint(a, b ConnectionConfig) bool {
+	return reflect.DeepEqual(endpoint(a), endpoint(b))
+}
+
+// endpoint returns config without the settings that can change on an open pool.
+func endpoint(config ConnectionConfig) ConnectionConfig {
+	config.MaxOpenConns, config.MaxIdleConns = nil, nil
+	config.ConnMaxLifetime, config.ConnMaxIdleTime = nil, nil
+	config.Name, config.Weight, config.Tags = "", 0, nil
+	config.Credentials = nil
+	if len(config.Params) == 0 {
+		config.Params = nil
+	}
+
+	// The slaves of a named connection are kept when only their pool limits
+	// or the slave strategy change
+	config.SlaveStrategy = ""
+	if len(config.Slaves) > 0 {
+		slaves := make([]ConnectionConfig, len(config.Slaves))
+		for i, slave := range config.Slaves {
+			slaves[i] = endpoint(slave)
+			slaves[i].Name, slaves[i].Weight, slaves[i].Tags = slave.Name, slave.Weight, slave.Tags
+		}
+		config.Slaves = slaves
+	}
+	return config
+}
+
+// reloadPool applies the pool limits of a resolved connection config to an
+// open connection.
+func (m *Manager) reloadPool(db *gorm.DB, config ConnectionConfig) {
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		configurePool(sqlDB, config)
+	}
+}
+
+// reloadSlaves brings the replica registry in line with next.Slaves and
+// installs selector when it is not nil. Replicas added with AddReplica that
+// are not in next.Slaves are removed.
+func (m *Manager) reloadSlaves(current, next Config, selector ReplicaSelector) error {
+	// Lazy slaves that are not connected yet read next.Slaves on first use
+	m.slavesInit.mu.Lock()
+	defer m.slavesInit.mu.Unlock()
+
+	if !m.slavesInit.done.Load() {
+		if selector != nil {
+			m.slaveMu.Lock()
+			m.selector = selector
+			m.slaveMu.Unlock()
+		}
+		return nil
+	}
+
+	// Registry entries by name with the fields read below, taken under slaveMu
+	type runningReplica struct {
+		entry  *replica
+		db     *gorm.DB
+		config ConnectionConfig
+	}
+	running := make(map[string]runningReplica)
+	m.slaveMu.Lock()
+	for _, r := range m.replicas {
+		running[r.name] = runningReplica{entry: r, db: r.db, config: r.config}
+	}
+	m.slaveMu.Unlock()
+
+	// Connect new and changed slaves before touching the registry
+	type slaveUpdate struct {
+		config      ConnectionConfig
+		entry       *replica // Registry entry to keep or add
+		reconfigure bool     // Apply config to a kept entry
+	}
+	updates := make([]slaveUpdate, 0, len(next.Slaves))
+	var errs []error
+	for i, slaveConfig := range next.Slaves {
+		name := replicaName(slaveConfig, i)
+		r, exists := running[name]
+		exists = exists && r.db != nil
+
+		if exists && sameEndpoint(current.inherit(r.config), next.inherit(slaveConfig)) {
+			m.reloadPool(r.db, next.inherit(slaveConfig))
+			updates = append(updates, slaveUpdate{config: slaveConfig, entry: r.entry, reconfigure: true})
+			continue
+		}
+
+		db, err := m.connectNode(context.Background(), name, slaveConfig)
+		if err != nil {
+			m.logWarn("Failed to connect to slave", "slave", name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to connect to slave %s: %w", name, err))
+			if exists {
+				// Keep serving reads from the old pool
+				updates = append(updates, slaveUpdate{config: slaveConfig, entry: r.entry})
+				continue
+			}
+			added := newReplica(name, slaveConfig)
+			added.state, added.err = ReplicaFailed, err
+			updates = append(updates, slaveUpdate{config: slaveConfig, entry: added})
+			continue
+		}
+
+		added := newReplica(name, slaveConfig)
+		added.db, added.state = db, ReplicaConnected
+		added.inRotation = true
+		updates = append(updates, slaveUpdate{config: slaveConfig, entry: added})
+	}
+
+	m.slaveMu.Lock()
+	kept := make(map[*replica]bool, len(updates))
+	replicas := make([]*replica, 0, len(updates))
+	for _, update := range updates {
+		if update.reconfigure {
+			update.entry.config = update.config
+		}
+		replicas = append(replicas, update.entry)
+		kept[update.entry] = true
+	}
+
+	// Entries removed from the config or replaced by a new pool
+	var retired []*replica
+	for _, r := range m.replicas {
+		if kept[r] {
+			continue
+		}
+		if r.state == ReplicaConnected {
+			r.state = ReplicaDraining
+		}
+		retired = append(retired, r)
+	}
+
+	m.replicas = replicas
+	if selector != nil {
+		m.selector = selector
+	}
+	m.slaveMu.Unlock()
+
+	for _, r := range retired {
+		m.retireReplica(r)
+	}
+
+	return errors.Join(errs...)
+}
+
+// reloadConnections brings the named connections in line with next.Connections.
+// Connections added with AddConnection that are not in next.Connections are
+// removed.
+func (m *Manager) reloadConnections(current, next Config) error {
+	m.connMu.RLock()
+	var removed []string
+	for name := range m.connections {
+		if _, keep := next.Connections[name]; !keep {
+			removed = append(removed, name)
+		}
+	}
+	for name := range m.connInit {
+		_, keep := next.Connections[name]
+		_, open := m.connections[name]
+		if !keep && !open {
+			removed = append(removed, name)
+		}
+	}
+	m.connMu.RUnlock()
+
+	for _, name := range removed {
+		_ = m.RemoveConnection(name)
+	}
+
+	var errs []error
+	for name, connConfig := range next.Connections {
+		m.connMu.RLock()
+		conn, open := m.connections[name]
+		_, pending := m.connInit[name]
+		m.connMu.RUnlock()
+
+		previous, configured := current.Connections[name]
+		switch {
+		case open && configured && sameEndpoint(current.inherit(previous), next.inherit(connConfig)):
+			m.reloadPool(conn, next.inherit(connConfig))
+			if c := m.cluster(name); c != nil {
+				m.reloadCluster(c, next.inherit(connConfig))
+			}
+			continue
+		case !open && pending:
+			// Connects with the new config on first use
+			continue
+		case !open && next.Lazy:
+			m.connMu.Lock()
+			m.connInit[name] = &lazyInit{}
+			m.connMu.Unlock()
+			continue
+		}
+
+		// Open the new pool before closing the old one
+		db, c, err := m.connectNamed(context.Background(), name, connConfig)
+		if err != nil {
+			m.logWarn("Failed to connect to named connection", "name", name, "error", err)
+			errs = append(errs, fmt.Errorf("failed to connect to named connection %s: %w", name, err))
+			continue
+		}
+
+		replaced, replacedCluster, err := m.storeConnection(name, db, c)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if replaced == nil {
+			m.logInfo("Named connection established", "name", name)
+			continue
+		}
+
+		// Close waits for the queries running on the old pool; it is the
+		// connection storeConnection replaced, which a lazy connect may have
+		// installed after conn was read
+		if err := closeNamed(replaced, replacedCluster); err != nil {
+			m.logWarn("Failed to close replaced connection", "name", name, "error", err)
+		}
+		m.logInfo("Named connection replaced", "name", name)
+	}
+
+	return errors.Join(errs...)
+}
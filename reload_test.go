@@ -0,0 +1,389 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newReloadConfig returns a config with a master, two slaves and a named
+// connection in dir.
+func newReloadConfig(dir string) Config {
+	return Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		MaxOpenConns:       10,
+		ReadWriteSplitting: true,
+		AutoRouting:        true,
+		Slaves: []ConnectionConfig{
+			{FilePath: filepath.Join(dir, "slave0.db")},
+			{FilePath: filepath.Join(dir, "slave1.db")},
+		},
+		Connections: map[string]ConnectionConfig{
+			"reports": {FilePath: filepath.Join(dir, "reports.db")},
+		},
+	}
+}
+
+// seedSource creates the stickyItem table in a database with a row naming it.
+func seedSource(t *testing.T, db *gorm.DB, name string) {
+	t.Helper()
+	db = db.Session(&gorm.Session{Context: SkipRouting(context.Background())})
+	require.NoError(t, db.AutoMigrate(&stickyItem{}))
+	require.NoError(t, db.Create(&stickyItem{Name: name}).Error)
+}
+
+func maxOpenConnections(t *testing.T, db *gorm.DB) int {
+	t.Helper()
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	return sqlDB.Stats().MaxOpenConnections
+}
+
+// TestManager_Reload_PoolLimits tests that pool limits change on open connections
+func TestManager_Reload_PoolLimits(t *testing.T) {
+	config := newReloadConfig(t.TempDir())
+	limit := 4
+	config.Connections["reports"] = ConnectionConfig{FilePath: config.Connections["reports"].FilePath, MaxOpenConns: &limit}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	reports := manager.Connection("reports")
+	slave := manager.Slave("slave_0")
+	assert.Equal(t, 10, maxOpenConnections(t, manager.DB()))
+	assert.Equal(t, 4, maxOpenConnections(t, reports))
+
+	config.MaxOpenConns = 20
+	limit = 6
+	require.NoError(t, manager.Reload(config))
+
+	assert.Equal(t, 20, maxOpenConnections(t, manager.DB()))
+	assert.Equal(t, 20, maxOpenConnections(t, slave))
+	assert.Equal(t, 6, maxOpenConnections(t, reports))
+
+	// The pools were kept
+	assert.Same(t, reports, manager.Connection("reports"))
+	assert.Same(t, slave, manager.Slave("slave_0"))
+}
+
+// TestManager_Reload_Topology tests adding, replacing and removing slaves and named connections
+func TestManager_Reload_Topology(t *testing.T) {
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	oldReports := manager.Connection("reports")
+	oldSlave := manager.Slave("slave_1")
+
+	config.Slaves = []ConnectionConfig{
+		config.Slaves[0],
+		{FilePath: filepath.Join(dir, "slave1-new.db")},
+		{Name: "replica-eu", FilePath: filepath.Join(dir, "eu.db"), Tags: map[string]string{"zone": "eu"}},
+	}
+	config.Connections = map[string]ConnectionConfig{
+		"reports":   {FilePath: filepath.Join(dir, "reports-new.db")},
+		"analytics": {FilePath: filepath.Join(dir, "analytics.db")},
+	}
+	config.SlaveStrategy = StrategyLeastInUse
+	require.NoError(t, manager.Reload(config))
+
+	assert.Equal(t, []string{"slave_0", "slave_1", "replica-eu"}, manager.ReplicaNames())
+	assert.NotSame(t, oldSlave, manager.Slave("slave_1"))
+	assert.Error(t, oldSlave.Exec("SELECT 1").Error, "the replaced slave pool should be closed")
+	assert.Equal(t, map[string]string{"zone": "eu"}, manager.Topology().Slaves[2].Tags)
+	assert.IsType(t, &LeastInUseSelector{}, manager.selector)
+
+	seedSource(t, manager.Slave("replica-eu"), "replica-eu")
+	source, err := findSource(UseReplica(context.Background(), "replica-eu"), manager.DB())
+	require.NoError(t, err)
+	assert.Equal(t, "replica-eu", source)
+
+	assert.True(t, manager.HasConnection("analytics"))
+	assert.NotSame(t, oldReports, manager.Connection("reports"))
+	assert.Error(t, oldReports.Exec("SELECT 1").Error, "the replaced connection should be closed")
+
+	// Removing from the config removes them from the manager
+	config.Slaves = config.Slaves[:1]
+	config.Connections = nil
+	require.NoError(t, manager.Reload(config))
+
+	assert.Equal(t, []string{"slave_0"}, manager.ReplicaNames())
+	assert.False(t, manager.HasConnection("reports"))
+	assert.False(t, manager.HasConnection("analytics"))
+	assert.NotContains(t, manager.AllStats(), "replica-eu")
+}
+
+// TestManager_Reload_FailedChanges tests that failed changes are reported and the rest applied
+func TestManager_Reload_FailedChanges(t *testing.T) {
+	registerUnavailableDriver(t, "test-unavailable-reload")
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	slave := manager.Slave("slave_0")
+
+	config.MaxOpenConns = 5
+	config.Slaves = []ConnectionConfig{
+		{Driver: "test-unavailable-reload", Host: "slave0.internal"},
+		config.Slaves[1],
+		{Name: "down", Driver: "test-unavailable-reload", Host: "down.internal"},
+	}
+	err = manager.Reload(config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to slave slave_0")
+	assert.Contains(t, err.Error(), "failed to connect to slave down")
+
+	// slave_0 keeps its old pool, the new slave is registered as failed
+	assert.Same(t, slave, manager.Slave("slave_0"))
+	topology := manager.Topology()
+	require.Len(t, topology.Slaves, 3)
+	assert.Equal(t, ReplicaFailed, topology.Slaves[2].State)
+	assert.Equal(t, 5, maxOpenConnections(t, manager.DB()))
+}
+
+// TestManager_Reload_RestartRequired tests that settings that need a restart are rejected
+func TestManager_Reload_RestartRequired(t *testing.T) {
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{"primary", func(c *Config) { c.FilePath = filepath.Join(dir, "other.db") }, "primary connection changed"},
+		{"database", func(c *Config) { c.Database = "other" }, "primary connection changed"},
+		{"splitting", func(c *Config) { c.ReadWriteSplitting = false }, "ReadWriteSplitting changed"},
+		{"master", func(c *Config) { c.Master = ConnectionConfig{Host: "master.internal"} }, "master connection changed"},
+		{"routing", func(c *Config) { c.AutoRouting = false }, "AutoRouting changed"},
+		{"lazy", func(c *Config) { c.Lazy = true }, "Lazy changed"},
+		{"monitor", func(c *Config) { c.HealthMonitor.Enabled = true }, "health monitor changed"},
+		{"invalid", func(c *Config) { c.SlaveStrategy = "fastest" }, "invalid configuration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := newReloadConfig(dir)
+			next.MaxOpenConns = 50
+			tt.modify(&next)
+
+			err := manager.Reload(next)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+			assert.Equal(t, 10, maxOpenConnections(t, manager.DB()), "nothing should be applied")
+		})
+	}
+}
+
+// TestManager_Reload_Logging tests that slow query logging can be turned on at runtime
+func TestManager_Reload_Logging(t *testing.T) {
+	logger := &mockSlowQueryLogger{}
+	config := DefaultConfig().WithDriver("sqlite").WithDatabase(":memory:")
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	require.NoError(t, manager.DB().Exec("CREATE TABLE items (id INTEGER)").Error)
+	assert.Empty(t, logger.slowQueries())
+
+	config.SlowQuery = SlowQueryConfig{Enabled: true, Threshold: time.Nanosecond}
+	config.LogLevel = "silent"
+	require.NoError(t, manager.Reload(config))
+
+	var count int64
+	require.NoError(t, manager.DB().Table("items").Count(&count).Error)
+	assert.Len(t, logger.slowQueries(), 1)
+	assert.Equal(t, "silent", manager.settings().LogLevel)
+
+	// A higher threshold applies to open connections too
+	config.SlowQuery.Threshold = time.Hour
+	require.NoError(t, manager.Reload(config))
+	require.NoError(t, manager.DB().Table("items").Count(&count).Error)
+	assert.Len(t, logger.slowQueries(), 1)
+}
+
+// TestManager_Reload_MonitorSettings tests that slave checks and sticky reads use the reloaded settings
+func TestManager_Reload_MonitorSettings(t *testing.T) {
+	lag := &fakeLag{lag: map[string]time.Duration{}, err: map[string]error{}}
+	lag.set("slave_a.db", time.Minute, nil)
+
+	manager := newLagManager(t, lag, time.Hour)
+	manager.stopHealthMonitor() // check synchronously below
+	manager.checkSlaves()
+	assert.True(t, manager.Topology().Slaves[0].Stale)
+
+	config := manager.settings()
+	config.MaxReplicationLag = 2 * time.Minute
+	config.StickyWindow = time.Minute
+	require.NoError(t, manager.Reload(config))
+
+	manager.checkSlaves()
+	assert.False(t, manager.Topology().Slaves[0].Stale)
+	assert.Equal(t, time.Minute, manager.stickyWindow())
+}
+
+// TestManager_Reload_Lazy tests that connections not opened yet use the new config
+func TestManager_Reload_Lazy(t *testing.T) {
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+	config.Lazy = true
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	config.MaxOpenConns = 3
+	config.Slaves = config.Slaves[1:]
+	config.Connections["reports"] = ConnectionConfig{FilePath: filepath.Join(dir, "reports-new.db")}
+	config.Connections["analytics"] = ConnectionConfig{FilePath: filepath.Join(dir, "analytics.db")}
+	require.NoError(t, manager.Reload(config))
+
+	assert.Nil(t, manager.db, "reload should not connect a lazy manager")
+	assert.Equal(t, 3, maxOpenConnections(t, manager.DB()))
+	assert.Equal(t, []string{"slave_0"}, manager.ReplicaNames())
+	assert.Equal(t, filepath.Join(dir, "slave1.db"), manager.replicas[0].config.FilePath)
+
+	require.NoError(t, manager.Connection("analytics").Exec("SELECT 1").Error)
+	require.NoError(t, manager.Connection("reports").Exec("CREATE TABLE marker (id INTEGER)").Error)
+	assert.FileExists(t, filepath.Join(dir, "reports-new.db"))
+	assert.NoFileExists(t, filepath.Join(dir, "reports.db"))
+}
+
+// TestManager_Reload_DuringReads tests reloading while reads are routed
+func TestManager_Reload_DuringReads(t *testing.T) {
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var n int
+				assert.NoError(t, manager.DB().Raw("SELECT 1").Scan(&n).Error)
+				assert.NoError(t, manager.Connection("reports").Raw("SELECT 1").Scan(&n).Error)
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		config.MaxOpenConns = 10 + i
+		config.SlaveStrategy = []string{StrategyRoundRobin, StrategyLeastInUse}[i%2]
+		config.Slaves = append(config.Slaves[:1], ConnectionConfig{FilePath: filepath.Join(dir, fmt.Sprintf("slave1-%d.db", i))})
+		require.NoError(t, manager.Reload(config))
+	}
+
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, []string{"slave_0", "slave_1"}, manager.ReplicaNames())
+}
+
+// TestManager_Reload_Concurrent tests that concurrent reloads close every replaced named connection
+func TestManager_Reload_Concurrent(t *testing.T) {
+	var mu sync.Mutex
+	var pools []*sql.DB
+	RegisterDriver("test-recording-sqlite", func(config DSNBuilder) (gorm.Dialector, error) {
+		sqlDB, err := sql.Open("sqlite3", config.GetFilePath())
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		pools = append(pools, sqlDB)
+		mu.Unlock()
+		return &sqlite.Dialector{Conn: sqlDB}, nil
+	})
+	dir := t.TempDir()
+	config := newReloadConfig(dir)
+	config.Connections["reports"] = ConnectionConfig{Driver: "test-recording-sqlite", FilePath: filepath.Join(dir, "reports.db")}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		next := config
+		next.Connections = map[string]ConnectionConfig{
+			"reports": {Driver: "test-recording-sqlite", FilePath: filepath.Join(dir, fmt.Sprintf("reports-%d.db", i))},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, manager.Reload(next))
+		}()
+	}
+	wg.Wait()
+
+	current, err := manager.Connection("reports").DB()
+	require.NoError(t, err)
+	require.Len(t, pools, 9)
+	for _, pool := range pools {
+		if pool == current {
+			assert.NoError(t, pool.Ping())
+		} else {
+			assert.ErrorContains(t, pool.Ping(), "database is closed")
+		}
+	}
+}
+
+// recordingWriter records the arguments of GORM log lines.
+type recordingWriter struct {
+	lines [][]interface{}
+}
+
+func (w *recordingWriter) Printf(format string, args ...interface{}) {
+	w.lines = append(w.lines, args)
+}
+
+// TestReloadableLogger_Caller tests that GORM log lines name the caller, not the logger
+func TestReloadableLogger_Caller(t *testing.T) {
+	writer := &recordingWriter{}
+	gormLogger := &reloadableLogger{writer: writer}
+	gormLogger.set("info", 0)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger})
+	require.NoError(t, err)
+
+	writer.lines = nil
+	require.NoError(t, db.Exec("SELECT 1").Error)
+
+	require.Len(t, writer.lines, 1)
+	assert.Contains(t, writer.lines[0][0], "reload_test.go:")
+
+	gormLogger.set("silent", 0)
+	require.NoError(t, db.Exec("SELECT 1").Error)
+	assert.Len(t, writer.lines, 1, "silent after reload")
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ReplicaState is the connection state of a replica in the registry.
+type ReplicaState string
+
+const (
+	// ReplicaConnected indicates the replica is connected and can serve reads.
+	ReplicaConnected ReplicaState = "connected"
+	// ReplicaFailed indicates the replica failed to connect.
+	ReplicaFailed ReplicaState = "failed"
+	// ReplicaDraining indicates the replica is connected but receives no new reads.
+	ReplicaDraining ReplicaState = "draining"
+	// ReplicaDrained indicates the replica was drained and its connection closed.
+	ReplicaDrained ReplicaState = "drained"
+)
+
+// replica is an entry in the replica registry: a configured slave, its
+// connection and its routing state. Fields other than name are guarded by
+// Manager.slaveMu.
+type replica struct {
+	name   string
+	config ConnectionConfig // As configured, before inheriting main config settings
+
+	db    *gorm.DB // nil unless connected
+	state ReplicaState
+	err   error           // Connection error of a failed replica
+	reads *sync.WaitGroup // Reads routed to the replica by the plugin that have not finished
+
+	slaveHealth
+}
+
+// replicaName returns the registry name of the slave at index i of Config.Slaves.
+func replicaName(config ConnectionConfig, i int) string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return fmt.Sprintf("slave_%d", i)
+}
+
+// newReplica creates a registry entry for a slave that is not connected yet.
+func newReplica(name string, config ConnectionConfig) *replica {
+	return &replica{name: name, config: config, reads: &sync.WaitGroup{}}
+}
+
+// connected reports whether the replica has an open connection. Draining
+// replicas are connected but not routable.
+func (r *replica) connected() bool {
+	return r.db != nil
+}
+
+// routable reports whether new reads may be routed to the replica.
+func (r *replica) routable() bool {
+	return r.state == ReplicaConnected && r.available()
+}
+
+// info describes the replica for the replica selector. slaveMu must be held.
+func (r *replica) info() ReplicaInfo {
+	info := ReplicaInfo{
+		Name:                r.name,
+		Host:                r.config.Host,
+		Weight:              r.config.Weight,
+		Tags:                r.config.Tags,
+		Latency:             r.ewma,
+		ReplicationLag:      r.lag,
+		ConsecutiveFailures: r.failures,
+	}
+	if sqlDB, err := r.db.DB(); err == nil {
+		info.Stats = sqlDB.Stats()
+	}
+	return info
+}
+
+// replicaSnapshot returns copies of the registry entries that can be read
+// without holding slaveMu.
+func (m *Manager) replicaSnapshot() []replica {
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	replicas := make([]replica, len(m.replicas))
+	for i, r := range m.replicas {
+		replicas[i] = *r
+	}
+	return replicas
+}
+
+// connectedReplicas is replicaSnapshot limited to connected and draining replicas.
+func (m *Manager) connectedReplicas() []replica {
+	var replicas []replica
+	for _, r := range m.replicaSnapshot() {
+		if r.connected() {
+			replicas = append(replicas, r)
+		}
+	}
+	return replicas
+}
+
+// replicaByName returns the registry entry with the given name, or nil.
+func (m *Manager) replicaByName(name string) *replica {
+	m.ensureSlaves()
+
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	for _, r := range m.replicas {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// ReplicaNames returns the names of the configured replicas in configuration
+// order, including replicas that failed to connect.
+func (m *Manager) ReplicaNames() []string {
+	m.ensureSlaves()
+
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	names := make([]string, len(m.replicas))
+	for i, r := range m.replicas {
+		names[i] = r.name
+	}
+	return names
+}
+
+// ========== Runtime Replica Management ==========
+
+// AddReplica connects a new slave and adds it to read routing under name.
+// The connection inherits unset settings from the main config like the slaves
+// in Config.Slaves. Reads already in progress are not affected.
+func (m *Manager) AddReplica(name string, config ConnectionConfig) error {
+	if m.closed.Load() {
+		return ErrManagerClosed
+	}
+	if !m.config.ReadWriteSplitting {
+		return fmt.Errorf("failed to add replica %s: read/write splitting is not enabled", name)
+	}
+	if name == "" {
+		return fmt.Errorf("replica name is required")
+	}
+	if config.Driver != "" && !isDriverRegistered(config.Driver) {
+		return fmt.Errorf("failed to add replica %s: unsupported driver: %s", name, config.Driver)
+	}
+
+	// Connect the configured slaves first so they do not replace the new one
+	m.ensureSlaves()
+	if m.replicaByName(name) != nil {
+		return fmt.Errorf("replica already exists: %s", name)
+	}
+
+	db, err := m.connectNode(context.Background(), name, config)
+	if err != nil {
+		return fmt.Errorf("failed to add replica %s: %w", name, err)
+	}
+
+	config.Name = name
+	r := newReplica(name, config)
+	r.db, r.state = db, ReplicaConnected
+	r.inRotation = true
+
+	m.slaveMu.Lock()
+	for _, existing := range m.replicas {
+		if existing.name == name {
+			m.slaveMu.Unlock()
+			closeReplicas([]*replica{r})
+			return fmt.Errorf("replica already exists: %s", name)
+		}
+	}
+	m.replicas = append(m.replicas, r)
+	m.slaveMu.Unlock()
+
+	m.logInfo("Replica added", "replica", name)
+	return nil
+}
+
+// DrainReplica stops routing new reads to a replica, waits for the reads
+// routed to it by automatic routing to finish and for every connection of its
+// pool to be returned, including those held by rows still being iterated, and
+// closes its connection. The replica stays in the registry as drained until
+// RemoveReplica or a Reload reconnects it. Connections obtained earlier through
+// Read or Slave are closed as well.
+func (m *Manager) DrainReplica(name string) error {
+	m.ensureSlaves()
+
+	r, err := m.startDrain(name)
+	if err != nil {
+		return err
+	}
+	m.logInfo("Replica draining", "replica", name)
+
+	m.closeReplica(r)
+
+	m.slaveMu.Lock()
+	r.state = ReplicaDrained
+	m.slaveMu.Unlock()
+
+	m.logInfo("Replica drained", "replica", name)
+	return nil
+}
+
+// startDrain takes a connected replica out of read routing.
+func (m *Manager) startDrain(name string) (*replica, error) {
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+
+	for _, r := range m.replicas {
+		if r.name != name {
+			continue
+		}
+		if r.state != ReplicaConnected {
+			return nil, fmt.Errorf("replica %s is %s", name, r.state)
+		}
+		r.state = ReplicaDraining
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("replica not found: %s", name)
+}
+
+// RemoveReplica drains a replica, waits for the reads routed to it by automatic
+// routing to finish and closes its connection. Connections obtained earlier
+// through Read or Slave are closed as well once their queries finish.
+func (m *Manager) RemoveReplica(name string) error {
+	m.ensureSlaves()
+
+	m.slaveMu.Lock()
+	index := -1
+	for i, r := range m.replicas {
+		if r.name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		m.slaveMu.Unlock()
+		return fmt.Errorf("replica not found: %s", name)
+	}
+
+	r := m.replicas[index]
+	if r.state == ReplicaConnected {
+		r.state = ReplicaDraining
+	}
+	m.replicas = append(m.replicas[:index], m.replicas[index+1:]...)
+	m.slaveMu.Unlock()
+
+	m.retireReplica(r)
+	return nil
+}
+
+// retireReplica waits for the reads routed to a replica that was taken out of
+// the registry and closes its connection.
+func (m *Manager) retireReplica(r *replica) {
+	m.closeReplica(r)
+	m.logInfo("Replica removed", "replica", r.name)
+}
+
+// closeReplica waits for the reads routed to a replica that no longer receives
+// new reads and closes its connection. A pool closed meanwhile by Shutdown is
+// closed again harmlessly.
+func (m *Manager) closeReplica(r *replica) {
+	r.reads.Wait()
+
+	m.slaveMu.Lock()
+	db := r.db
+	r.db = nil
+	m.slaveMu.Unlock()
+
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		waitPoolIdle(sqlDB)
+		if err := sqlDB.Close(); err != nil {
+			m.logWarn("Failed to close replica", "replica", r.name, "error", err)
+		}
+	}
+}
+
+// waitPoolIdle waits until no connection of a replica pool is in use. Rows
+// returned by Rows or Raw keep their connection after the read is counted as
+// finished, until they are closed.
+func waitPoolIdle(sqlDB *sql.DB) {
+	_ = waitIdle(context.Background(), []openPool{{db: sqlDB}})
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManager_WeightsFollowFailedSlave tests that a slave failing to connect
+// does not shift the weights of the slaves after it
+func TestManager_WeightsFollowFailedSlave(t *testing.T) {
+	registerUnavailableDriver(t, "test-unavailable-weighted")
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		SlaveStrategy:      StrategyWeighted,
+		Slaves: []ConnectionConfig{
+			{Driver: "test-unavailable-weighted", Host: "down.internal", Weight: 10},
+			{FilePath: filepath.Join(dir, "slave_b.db"), Weight: 0},
+			{FilePath: filepath.Join(dir, "slave_c.db"), Weight: 1},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	require.Len(t, manager.replicas, 3)
+	assert.Equal(t, ReplicaFailed, manager.replicas[0].state)
+
+	for i := 0; i < 20; i++ {
+		assert.Same(t, manager.replicas[2].db, manager.selectSlave(), "only slave_2 has a positive weight")
+	}
+
+	// Names keep their configuration index
+	stats := manager.AllStats()
+	assert.NotContains(t, stats, "slave_0")
+	assert.Contains(t, stats, "slave_1")
+	assert.Contains(t, stats, "slave_2")
+
+	health := manager.HealthCheck()
+	assert.False(t, health["slave_0"])
+	assert.True(t, health["slave_2"])
+
+	topology := manager.Topology()
+	require.Len(t, topology.Slaves, 3)
+	assert.Equal(t, "down.internal", topology.Slaves[0].Host)
+	assert.False(t, topology.Slaves[0].InRotation)
+	assert.ErrorContains(t, topology.Slaves[0].LastError, "connection refused")
+}
+
+// TestManager_NamedReplicas tests lookups and stats by configured replica name
+func TestManager_NamedReplicas(t *testing.T) {
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		AutoRouting:        true,
+		Slaves: []ConnectionConfig{
+			{Name: "reporting", FilePath: filepath.Join(dir, "reporting.db"), Tags: map[string]string{"zone": "eu"}},
+			{FilePath: filepath.Join(dir, "slave.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Equal(t, []string{"reporting", "slave_1"}, manager.ReplicaNames())
+	assert.Same(t, manager.replicas[0].db, manager.Slave("reporting"))
+	assert.Same(t, manager.replicas[1].db, manager.Slave("slave_1"))
+	assert.Contains(t, manager.AllStats(), "reporting")
+
+	// Unknown names fall back to master
+	sqlDB, err := manager.Slave("slave_0").DB()
+	require.NoError(t, err)
+	masterDB, err := manager.writer().DB()
+	require.NoError(t, err)
+	assert.Same(t, masterDB, sqlDB)
+
+	require.NoError(t, manager.replicas[0].db.AutoMigrate(&stickyItem{}))
+	require.NoError(t, manager.replicas[0].db.Create(&stickyItem{Name: "report"}).Error)
+	source, err := findSource(UseReplica(context.Background(), "reporting"), manager.DB())
+	require.NoError(t, err)
+	assert.Equal(t, "report", source)
+
+	topology := manager.Topology()
+	assert.Equal(t, map[string]string{"zone": "eu"}, topology.Slaves[0].Tags)
+	assert.Equal(t, ReplicaConnected, topology.Slaves[0].State)
+}
+
+// TestConfigValidate_DuplicateReplicaName tests that replica names must be unique
+func TestConfigValidate_DuplicateReplicaName(t *testing.T) {
+	config := Config{
+		Driver:             "sqlite",
+		Database:           ":memory:",
+		ReadWriteSplitting: true,
+		Slaves: []ConnectionConfig{
+			{Database: ":memory:"},
+			{Name: "slave_0", Database: ":memory:"},
+		},
+	}
+
+	assert.EqualError(t, config.Validate(), "slave 1: duplicate replica name: slave_0")
+}
+
+// TestManager_AddReplica tests adding a replica at runtime
+func TestManager_AddReplica(t *testing.T) {
+	manager := newHintManager(t)
+	dir := t.TempDir()
+
+	added := filepath.Join(dir, "added.db")
+	require.NoError(t, manager.AddReplica("added", ConnectionConfig{FilePath: added, Tags: map[string]string{"zone": "us"}}))
+	assert.Equal(t, []string{"slave_0", "slave_1", "added"}, manager.ReplicaNames())
+
+	require.NoError(t, manager.Slave("added").AutoMigrate(&stickyItem{}))
+	require.NoError(t, manager.Slave("added").Create(&stickyItem{Name: "added"}).Error)
+
+	source, err := findSource(UseReplica(context.Background(), "added"), manager.DB())
+	require.NoError(t, err)
+	assert.Equal(t, "added", source)
+
+	err = manager.AddReplica("added", ConnectionConfig{FilePath: added})
+	assert.EqualError(t, err, "replica already exists: added")
+
+	err = manager.AddReplica("broken", ConnectionConfig{Driver: "nope"})
+	assert.EqualError(t, err, "failed to add replica broken: unsupported driver: nope")
+}
+
+// TestManager_AddReplica_RequiresReadWriteSplitting tests that replicas need read/write splitting
+func TestManager_AddReplica_RequiresReadWriteSplitting(t *testing.T) {
+	manager, err := NewManager(Config{Driver: "sqlite", Database: ":memory:"}, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	err = manager.AddReplica("extra", ConnectionConfig{Database: ":memory:"})
+	assert.EqualError(t, err, "failed to add replica extra: read/write splitting is not enabled")
+}
+
+// TestManager_DrainReplica tests that a drained replica receives no new reads
+func TestManager_DrainReplica(t *testing.T) {
+	manager := newHintManager(t)
+
+	require.NoError(t, manager.DrainReplica("slave_0"))
+	for i := 0; i < 4; i++ {
+		source, err := findSource(context.Background(), manager.DB())
+		require.NoError(t, err)
+		assert.Equal(t, "slave_1", source)
+	}
+
+	// Explicit hints fall back to master
+	source, err := findSource(UseReplica(context.Background(), "slave_0"), manager.DB())
+	require.NoError(t, err)
+	assert.Equal(t, "master", source)
+
+	topology := manager.Topology()
+	assert.Equal(t, ReplicaDrained, topology.Slaves[0].State)
+	assert.False(t, topology.Slaves[0].InRotation)
+	assert.NotContains(t, manager.AllStats(), "slave_0", "a drained replica is closed")
+	assert.Equal(t, []string{"slave_0", "slave_1"}, manager.ReplicaNames())
+
+	assert.EqualError(t, manager.DrainReplica("slave_0"), "replica slave_0 is drained")
+	assert.EqualError(t, manager.DrainReplica("slave_9"), "replica not found: slave_9")
+
+	// A drained replica can still be removed
+	require.NoError(t, manager.RemoveReplica("slave_0"))
+	assert.Equal(t, []string{"slave_1"}, manager.ReplicaNames())
+}
+
+// TestManager_DrainReplica_WaitsForReads tests that draining closes the replica once its reads finish
+func TestManager_DrainReplica_WaitsForReads(t *testing.T) {
+	manager := newHintManager(t)
+
+	// A read routed to a slave that has not finished yet
+	slave, r := manager.pickSlave()
+	require.NotNil(t, r)
+	sqlDB, err := slave.DB()
+	require.NoError(t, err)
+
+	drained := make(chan error, 1)
+	go func() { drained <- manager.DrainReplica(r.name) }()
+
+	select {
+	case <-drained:
+		t.Fatal("DrainReplica returned while a read was in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The read can still run on the replica, which gets no new reads
+	var count int64
+	require.NoError(t, slave.Model(&stickyItem{}).Count(&count).Error)
+	for i := 0; i < 4; i++ {
+		_, next := manager.pickSlave()
+		require.NotNil(t, next)
+		assert.NotEqual(t, r.name, next.name)
+		next.reads.Done()
+	}
+	r.reads.Done()
+
+	require.NoError(t, <-drained)
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+	for _, status := range manager.Topology().Slaves {
+		if status.Name == r.name {
+			assert.Equal(t, ReplicaDrained, status.State)
+		}
+	}
+}
+
+// TestManager_DrainReplica_WaitsForRows tests that draining waits for rows still being iterated
+func TestManager_DrainReplica_WaitsForRows(t *testing.T) {
+	manager := newHintManager(t)
+
+	// The read callbacks have finished once Rows returns
+	rows, err := manager.DB().WithContext(UseReplica(context.Background(), "slave_0")).Model(&stickyItem{}).Rows()
+	require.NoError(t, err)
+
+	drained := make(chan error, 1)
+	go func() { drained <- manager.DrainReplica("slave_0") }()
+
+	select {
+	case <-drained:
+		t.Fatal("DrainReplica returned while rows were being iterated")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var item stickyItem
+	require.True(t, rows.Next())
+	require.NoError(t, manager.DB().ScanRows(rows, &item))
+	assert.Equal(t, "slave_0", item.Name)
+	require.NoError(t, rows.Close())
+
+	require.NoError(t, <-drained)
+}
+
+// TestManager_RemoveReplica_WaitsForReads tests that removal waits for routed reads
+func TestManager_RemoveReplica_WaitsForReads(t *testing.T) {
+	manager := newHintManager(t)
+	require.NoError(t, manager.DrainReplica("slave_1"))
+
+	// A read routed to slave_0 that has not finished yet
+	slave, r := manager.pickSlave()
+	require.NotNil(t, r)
+	require.Equal(t, "slave_0", r.name)
+
+	removed := make(chan error, 1)
+	go func() { removed <- manager.RemoveReplica("slave_0") }()
+
+	select {
+	case <-removed:
+		t.Fatal("RemoveReplica returned while a read was in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The read can still run on the replica
+	var count int64
+	require.NoError(t, slave.Model(&stickyItem{}).Count(&count).Error)
+	r.reads.Done()
+
+	require.NoError(t, <-removed)
+	assert.Equal(t, []string{"slave_1"}, manager.ReplicaNames())
+	assert.Error(t, slave.Exec("SELECT 1").Error, "the replica pool should be closed")
+	assert.EqualError(t, manager.RemoveReplica("slave_0"), "replica not found: slave_0")
+}
+
+// TestManager_ReplicaChangesDuringReads tests adding and removing replicas while reads are routed
+func TestManager_ReplicaChangesDuringReads(t *testing.T) {
+	manager := newHintManager(t)
+	dir := t.TempDir()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var n int
+				assert.NoError(t, manager.DB().Raw("SELECT 1").Scan(&n).Error)
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("extra_%d", i)
+		db := filepath.Join(dir, name+".db")
+		require.NoError(t, manager.AddReplica(name, ConnectionConfig{FilePath: db}))
+		time.Sleep(time.Millisecond)
+		require.NoError(t, manager.RemoveReplica(name))
+	}
+
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, []string{"slave_0", "slave_1"}, manager.ReplicaNames())
+}
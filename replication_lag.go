@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplicationLagFunc returns how far a slave connection is behind its master.
+// It is called by the background monitor with a context bounded by
+// HealthMonitor.Timeout. Custom implementations can be set as Config.ReplicationLag,
+// for example to read lag from a heartbeat table or to fake it in tests.
+type ReplicationLagFunc func(ctx context.Context, db *gorm.DB) (time.Duration, error)
+
+// postgresLagQuery returns the replay lag in seconds. A primary, or a replica
+// that has replayed everything it received, reports no lag even when the last
+// replayed transaction is old.
+const postgresLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// PostgresReplicationLag measures lag from pg_last_xact_replay_timestamp().
+func PostgresReplicationLag(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	var seconds float64
+	if err := db.WithContext(ctx).Raw(postgresLagQuery).Scan(&seconds).Error; err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MySQLReplicationLag measures lag from Seconds_Behind_Source in SHOW REPLICA STATUS,
+// falling back to Seconds_Behind_Master in SHOW SLAVE STATUS on servers before
+// MySQL 8.0.22 that reject the newer statement. A server that is not a replica
+// reports no lag; a replica whose replication is stopped returns an error.
+func MySQLReplicationLag(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	lag, err := mysqlReplicaStatusLag(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil && !errors.Is(err, errReplicationStopped) && ctx.Err() == nil {
+		if legacyLag, legacyErr := mysqlReplicaStatusLag(ctx, db, "SHOW SLAVE STATUS"); legacyErr == nil {
+			return legacyLag, nil
+		}
+	}
+	return lag, err
+}
+
+// errReplicationStopped is returned for a replica whose SQL thread is not running.
+var errReplicationStopped = errors.New("replication is not running")
+
+// mysqlReplicaStatusLag reads the lag column from a replica status statement.
+func mysqlReplicaStatusLag(ctx context.Context, db *gorm.DB, statement string) (time.Duration, error) {
+	rows, err := db.WithContext(ctx).Raw(statement).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errReplicationStopped
+		}
+
+		var seconds int64
+		if _, err := fmt.Sscan(string(values[i]), &seconds); err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", column, values[i], err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, errors.New("replica status has no Seconds_Behind_Source column")
+}
+
+// defaultReplicationLag returns the built-in lag source for a driver, or nil.
+func defaultReplicationLag(driver string) ReplicationLagFunc {
+	switch driver {
+	case "postgres":
+		return PostgresReplicationLag
+	case "mysql":
+		return MySQLReplicationLag
+	default:
+		return nil
+	}
+}
+
+// replicationLagSource returns the lag source for a slave of the config.
+func (c Config) replicationLagSource(slave ConnectionConfig) ReplicationLagFunc {
+	return c.lagSourceFor(c.inherit(slave).Driver)
+}
+
+// lagSourceFor returns the lag source for a slave using driver.
+func (c Config) lagSourceFor(driver string) ReplicationLagFunc {
+	if c.ReplicationLag != nil {
+		return c.ReplicationLag
+	}
+	return defaultReplicationLag(driver)
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeLag is a ReplicationLagFunc that reports a configurable lag per slave.
+// Slaves are identified by their sqlite file name.
+type fakeLag struct {
+	mu  sync.Mutex
+	lag map[string]time.Duration
+	err map[string]error
+}
+
+func (f *fakeLag) set(file string, lag time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lag[file] = lag
+	f.err[file] = err
+}
+
+func (f *fakeLag) measure(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	var file string
+	if err := db.WithContext(ctx).Raw("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file).Error; err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := filepath.Base(file)
+	return f.lag[name], f.err[name]
+}
+
+func newLagManager(t *testing.T, lag *fakeLag, interval time.Duration) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		SlaveStrategy:      "round-robin",
+		MaxReplicationLag:  5 * time.Second,
+		ReplicationLag:     lag.measure,
+		HealthMonitor:      HealthMonitorConfig{Interval: interval},
+		Slaves: []ConnectionConfig{
+			{FilePath: filepath.Join(dir, "slave_a.db")},
+			{FilePath: filepath.Join(dir, "slave_b.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager
+}
+
+// TestReplicationLag_SkipsStaleSlaves tests that slaves over the lag threshold are skipped
+func TestReplicationLag_SkipsStaleSlaves(t *testing.T) {
+	lag := &fakeLag{lag: map[string]time.Duration{}, err: map[string]error{}}
+	lag.set("slave_a.db", time.Minute, nil)
+	lag.set("slave_b.db", time.Second, nil)
+
+	manager := newLagManager(t, lag, time.Hour)
+	manager.stopHealthMonitor() // check synchronously below
+	manager.checkSlaves()
+
+	topology := manager.Topology()
+	require.Len(t, topology.Slaves, 2)
+	assert.True(t, topology.Slaves[0].Stale)
+	assert.Equal(t, time.Minute, topology.Slaves[0].ReplicationLag)
+	assert.False(t, topology.Slaves[0].InRotation)
+	assert.False(t, topology.Slaves[1].Stale)
+
+	for i := 0; i < 4; i++ {
+		assert.Same(t, manager.replicas[1].db, manager.selectSlave())
+	}
+
+	// The slave is used again once it catches up
+	lag.set("slave_a.db", 0, nil)
+	manager.checkSlaves()
+	assert.True(t, manager.Topology().Slaves[0].InRotation)
+}
+
+// TestReplicationLag_AllStaleFallsBackToMaster tests the master fallback
+func TestReplicationLag_AllStaleFallsBackToMaster(t *testing.T) {
+	lag := &fakeLag{lag: map[string]time.Duration{}, err: map[string]error{}}
+	lag.set("slave_a.db", time.Minute, nil)
+	lag.set("slave_b.db", 0, errors.New("replication is not running"))
+
+	manager := newLagManager(t, lag, time.Hour)
+	manager.stopHealthMonitor() // check synchronously below
+	manager.checkSlaves()
+
+	assert.Nil(t, manager.selectSlave())
+	assert.Same(t, manager.master, manager.Read())
+}
+
+// TestReplicationLag_Background tests that the monitor polls lag without health checks enabled
+func TestReplicationLag_Background(t *testing.T) {
+	lag := &fakeLag{lag: map[string]time.Duration{}, err: map[string]error{}}
+	manager := newLagManager(t, lag, 10*time.Millisecond)
+
+	lag.set("slave_b.db", time.Hour, nil)
+
+	require.Eventually(t, func() bool {
+		return manager.Topology().Slaves[1].Stale
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.False(t, manager.Topology().Slaves[0].Stale)
+}
+
+// TestConfigValidate_ReplicationLag tests that lag checks need a lag source
+func TestConfigValidate_ReplicationLag(t *testing.T) {
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           "app.db",
+		ReadWriteSplitting: true,
+		MaxReplicationLag:  time.Second,
+		Slaves:             []ConnectionConfig{{FilePath: "replica.db"}},
+	}
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "replication lag is not supported for driver sqlite")
+
+	config.ReplicationLag = func(context.Context, *gorm.DB) (time.Duration, error) { return 0, nil }
+	assert.NoError(t, config.Validate())
+}
+
+// replicaStatusPool answers MySQL replica status statements with sqlite
+// queries; statements missing from statuses are rejected like an older server.
+type replicaStatusPool struct {
+	*sql.DB
+	statuses map[string]string
+}
+
+func (p replicaStatusPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if !strings.HasPrefix(query, "SHOW ") {
+		return p.DB.QueryContext(ctx, query, args...)
+	}
+	status, ok := p.statuses[query]
+	if !ok {
+		return nil, fmt.Errorf("Error 1064 (42000): You have an error in your SQL syntax near '%s'", query)
+	}
+	return p.DB.QueryContext(ctx, status)
+}
+
+// TestMySQLReplicationLag tests the replica status statement and column per server version
+func TestMySQLReplicationLag(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses map[string]string
+		lag      time.Duration
+		err      string
+	}{
+		{
+			name:     "replica status",
+			statuses: map[string]string{"SHOW REPLICA STATUS": "SELECT 'Yes' AS Replica_SQL_Running, 3 AS Seconds_Behind_Source"},
+			lag:      3 * time.Second,
+		},
+		{
+			name:     "slave status before 8.0.22",
+			statuses: map[string]string{"SHOW SLAVE STATUS": "SELECT 'Yes' AS Slave_SQL_Running, 7 AS Seconds_Behind_Master"},
+			lag:      7 * time.Second,
+		},
+		{
+			name:     "not a replica",
+			statuses: map[string]string{"SHOW REPLICA STATUS": "SELECT 0 AS Seconds_Behind_Source WHERE 0"},
+		},
+		{
+			name:     "replication stopped",
+			statuses: map[string]string{"SHOW REPLICA STATUS": "SELECT NULL AS Seconds_Behind_Source"},
+			err:      "replication is not running",
+		},
+		{
+			name:     "rejected",
+			statuses: map[string]string{},
+			err:      "SHOW REPLICA STATUS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, err := sql.Open(sqlite.DriverName, ":memory:")
+			require.NoError(t, err)
+			defer sqlDB.Close()
+
+			db, err := gorm.Open(sqlite.New(sqlite.Config{Conn: replicaStatusPool{DB: sqlDB, statuses: tt.statuses}}), &gorm.Config{})
+			require.NoError(t, err)
+
+			lag, err := MySQLReplicationLag(context.Background(), db)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.lag, lag)
+		})
+	}
+}
@@ -1,50 +1,141 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// ConnectAttempt describes a single failed connection attempt.
+type ConnectAttempt struct {
+	Number   int           // Attempt number, starting at 1
+	Duration time.Duration // Time spent on the attempt
+	Err      error         // Error returned by the attempt
+}
+
+// ConnectError is returned when a connection could not be established with retry enabled.
+// It lists every attempt that was made; there are fewer than MaxAttempts when an
+// error was not retryable. Cause is set when retrying stopped early because the
+// context was cancelled or its deadline expired.
+type ConnectError struct {
+	MaxAttempts int
+	Attempts    []ConnectAttempt
+	Cause       error
+}
+
+// Error returns a summary of all attempts.
+func (e *ConnectError) Error() string {
+	var b strings.Builder
+	if e.Cause != nil {
+		fmt.Fprintf(&b, "connection retry stopped after %d of %d attempts: %v",
+			len(e.Attempts), e.MaxAttempts, e.Cause)
+	} else if len(e.Attempts) < e.MaxAttempts {
+		fmt.Fprintf(&b, "failed to connect after %d of %d attempts (error is not retryable)",
+			len(e.Attempts), e.MaxAttempts)
+	} else {
+		fmt.Fprintf(&b, "failed to connect after %d attempts", len(e.Attempts))
+	}
+
+	for _, attempt := range e.Attempts {
+		fmt.Fprintf(&b, "; attempt %d (%s): %v", attempt.Number, attempt.Duration.Round(time.Millisecond), attempt.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause and the error of every attempt, so errors.Is and
+// errors.As match both context errors and driver errors.
+func (e *ConnectError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts)+1)
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt.Err)
+	}
+	return errs
+}
+
+// LastErr returns the error of the last attempt, or nil if no attempt was made.
+func (e *ConnectError) LastErr() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
 // connectWithRetry attempts to connect to the database with retry logic.
-func connectWithRetry(config ConnectionConfig, retryConfig RetryConfig, logger Logger) (*gorm.DB, error) {
+// Retrying stops as soon as ctx is done.
+func connectWithRetry(ctx context.Context, config ConnectionConfig, settings Config, logger Logger) (*gorm.DB, error) {
+	retryConfig := settings.Retry
 	if !retryConfig.Enabled {
 		// Retry disabled, use normal connection
-		return connectWithConfig(config, logger)
+		return openConnectionOnce(ctx, config, settings, logger)
 	}
 
-	var lastErr error
+	maxAttempts := max(retryConfig.MaxAttempts, 1)
+	retryable := retryConfig.Retryable
+	if retryable == nil {
+		retryable = IsRetryableError
+	}
+	connErr := &ConnectError{MaxAttempts: maxAttempts}
 	delay := retryConfig.InitialDelay
 
-	for attempt := 1; attempt <= retryConfig.MaxAttempts; attempt++ {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			connErr.Cause = err
+			return nil, connErr
+		}
+
 		// Attempt connection
-		db, err := connectWithConfig(config, logger)
+		start := time.Now()
+		db, err := openConnectionOnce(ctx, config, settings, logger)
 		if err == nil {
 			// Success!
 			if attempt > 1 && logger != nil {
 				logInfo(logger, "Database connection successful after retry",
 					"attempt", attempt,
-					"total_attempts", retryConfig.MaxAttempts)
+					"total_attempts", maxAttempts)
 			}
 			return db, nil
 		}
 
 		// Connection failed
-		lastErr = err
+		connErr.Attempts = append(connErr.Attempts, ConnectAttempt{
+			Number:   attempt,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+
+		// Fail fast on errors another attempt cannot fix
+		if !retryable(err) {
+			if logger != nil && attempt < maxAttempts {
+				logWarn(logger, "Database connection failed with a non-retryable error",
+					"attempt", attempt,
+					"error", err.Error())
+			}
+			break
+		}
 
 		// Don't sleep after the last attempt
-		if attempt < retryConfig.MaxAttempts {
+		if attempt < maxAttempts {
+			wait := jitterDelay(delay, retryConfig.Jitter)
 			if logger != nil {
 				logWarn(logger, "Database connection failed, retrying",
 					"attempt", attempt,
-					"max_attempts", retryConfig.MaxAttempts,
-					"delay", delay,
+					"max_attempts", maxAttempts,
+					"delay", wait,
 					"error", err.Error())
 			}
 
-			// Sleep before next retry
-			time.Sleep(delay)
+			// Sleep before next retry, unless ctx is done first
+			if err := sleepContext(ctx, wait); err != nil {
+				connErr.Cause = err
+				return nil, connErr
+			}
 
 			// Calculate next delay with exponential backoff
 			delay = time.Duration(float64(delay) * retryConfig.BackoffFactor)
@@ -54,7 +145,37 @@ func connectWithRetry(config ConnectionConfig, retryConfig RetryConfig, logger L
 		}
 	}
 
-	// All attempts failed
-	return nil, fmt.Errorf("failed to connect after %d attempts: %w",
-		retryConfig.MaxAttempts, lastErr)
+	// All attempts failed or the error was not retryable; record the context error if it ended the last attempt
+	connErr.Cause = ctx.Err()
+	return nil, connErr
+}
+
+// jitterDelay applies the jitter mode to a backoff delay.
+func jitterDelay(delay time.Duration, mode string) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+
+	switch mode {
+	case JitterFull:
+		return time.Duration(rand.Int63n(int64(delay) + 1))
+	case JitterEqual:
+		half := delay / 2
+		return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+	default:
+		return delay
+	}
+}
+
+// sleepContext waits for d or until ctx is done, returning the context error in the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
@@ -1,13 +1,35 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
+// registerUnavailableDriver registers a driver whose connection attempts always
+// fail and returns a counter of the attempts made.
+func registerUnavailableDriver(t *testing.T, name string) *atomic.Int32 {
+	t.Helper()
+
+	var attempts atomic.Int32
+	RegisterDriver(name, func(config DSNBuilder) (gorm.Dialector, error) {
+		attempts.Add(1)
+		return nil, errors.New("connection refused")
+	})
+	return &attempts
+}
+
 // TestDefaultRetryConfig tests the default retry configuration
 func TestDefaultRetryConfig(t *testing.T) {
 	config := DefaultRetryConfig()
@@ -131,3 +153,198 @@ func TestConnect_WithCustomRetryConfig(t *testing.T) {
 	assert.True(t, config.Retry.Enabled)
 	assert.Equal(t, 2, config.Retry.MaxAttempts)
 }
+
+// TestConnectWithRetry_ListsAttempts tests that the retry error lists every attempt
+func TestConnectWithRetry_ListsAttempts(t *testing.T) {
+	attempts := registerUnavailableDriver(t, "test-unavailable")
+
+	settings := Config{
+		LogLevel: "silent",
+		Retry: RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   3,
+			InitialDelay:  time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			BackoffFactor: 2,
+		},
+	}
+
+	_, err := connectWithRetry(context.Background(), ConnectionConfig{Driver: "test-unavailable"}, settings, nil)
+	require.Error(t, err)
+
+	var connErr *ConnectError
+	require.True(t, errors.As(err, &connErr))
+	assert.Len(t, connErr.Attempts, 3)
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Nil(t, connErr.Cause)
+	assert.Contains(t, err.Error(), "failed to connect after 3 attempts")
+	assert.Contains(t, connErr.LastErr().Error(), "connection refused")
+	for i, attempt := range connErr.Attempts {
+		assert.Equal(t, i+1, attempt.Number)
+	}
+}
+
+// TestNewManagerContext_CancelStopsRetry tests that cancelling the context ends the retry loop
+func TestNewManagerContext_CancelStopsRetry(t *testing.T) {
+	registerUnavailableDriver(t, "test-unavailable-cancel")
+
+	config := DefaultConfig().
+		WithDriver("test-unavailable-cancel").
+		WithHost("db.internal").
+		WithRetry(RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   5,
+			InitialDelay:  time.Minute,
+			MaxDelay:      time.Minute,
+			BackoffFactor: 2,
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewManagerContext(ctx, config, nil)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var connErr *ConnectError
+	require.True(t, errors.As(err, &connErr))
+	assert.Len(t, connErr.Attempts, 1)
+	assert.Equal(t, 5, connErr.MaxAttempts)
+}
+
+// TestNewManagerContext_RetriesSlaves tests that slaves use the same retry policy as the primary
+func TestNewManagerContext_RetriesSlaves(t *testing.T) {
+	attempts := registerUnavailableDriver(t, "test-unavailable-slave")
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithRetry(RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   2,
+			InitialDelay:  time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			BackoffFactor: 2,
+		})
+	config.ReadWriteSplitting = true
+	config.Slaves = []ConnectionConfig{
+		{Driver: "test-unavailable-slave", Host: "replica.internal"},
+	}
+
+	manager, err := NewManagerContext(context.Background(), config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Empty(t, manager.connectedReplicas())
+	require.Len(t, manager.Topology().Slaves, 1)
+	assert.Equal(t, ReplicaFailed, manager.Topology().Slaves[0].State)
+}
+
+// TestConnectWithRetry_NonRetryableError tests that permanent errors fail fast
+func TestConnectWithRetry_NonRetryableError(t *testing.T) {
+	var attempts atomic.Int32
+	RegisterDriver("test-access-denied", func(config DSNBuilder) (gorm.Dialector, error) {
+		attempts.Add(1)
+		return nil, &mysqldriver.MySQLError{Number: 1045, Message: "Access denied for user 'app'"}
+	})
+
+	settings := Config{
+		LogLevel: "silent",
+		Retry: RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   5,
+			InitialDelay:  time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			BackoffFactor: 2,
+		},
+	}
+
+	_, err := connectWithRetry(context.Background(), ConnectionConfig{Driver: "test-access-denied"}, settings, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Contains(t, err.Error(), "not retryable")
+}
+
+// TestConnectWithRetry_CustomRetryable tests that RetryableFunc overrides the default classifier
+func TestConnectWithRetry_CustomRetryable(t *testing.T) {
+	attempts := registerUnavailableDriver(t, "test-unavailable-custom")
+
+	settings := Config{
+		LogLevel: "silent",
+		Retry: RetryConfig{
+			Enabled:       true,
+			MaxAttempts:   4,
+			InitialDelay:  time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			BackoffFactor: 2,
+			Retryable:     func(err error) bool { return false },
+		},
+	}
+
+	_, err := connectWithRetry(context.Background(), ConnectionConfig{Driver: "test-unavailable-custom"}, settings, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+// TestIsRetryableError tests the default error classifier
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"generic", errors.New("dial tcp: connection refused"), true},
+		{"context canceled", context.Canceled, false},
+		{"mysql access denied", &mysqldriver.MySQLError{Number: 1045}, false},
+		{"mysql unknown database", &mysqldriver.MySQLError{Number: 1049}, false},
+		{"mysql too many connections", &mysqldriver.MySQLError{Number: 1040}, true},
+		{"mysql auth plugin", mysqldriver.ErrUnknownPlugin, false},
+		{"postgres invalid password", &pgconn.PgError{Code: "28P01"}, false},
+		{"postgres unknown database", &pgconn.PgError{Code: "3D000"}, false},
+		{"postgres starting up", &pgconn.PgError{Code: "57P03"}, true},
+		{"sqlite cannot open", sqlite3.Error{Code: sqlite3.ErrCantOpen}, false},
+		{"sqlite busy", sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{"sqlserver login failed", mssql.Error{Number: 18456}, false},
+		{"wrapped", fmt.Errorf("failed to connect: %w", &mysqldriver.MySQLError{Number: 1045}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, IsRetryableError(tt.err))
+		})
+	}
+}
+
+// TestJitterDelay tests that jittered delays stay within their bounds
+func TestJitterDelay(t *testing.T) {
+	delay := 100 * time.Millisecond
+
+	assert.Equal(t, delay, jitterDelay(delay, ""))
+	assert.Equal(t, delay, jitterDelay(delay, JitterNone))
+
+	for i := 0; i < 100; i++ {
+		full := jitterDelay(delay, JitterFull)
+		assert.GreaterOrEqual(t, full, time.Duration(0))
+		assert.LessOrEqual(t, full, delay)
+
+		equal := jitterDelay(delay, JitterEqual)
+		assert.GreaterOrEqual(t, equal, delay/2)
+		assert.LessOrEqual(t, equal, delay)
+	}
+}
+
+// TestConfigValidate_RetryJitter tests that unknown jitter modes are rejected
+func TestConfigValidate_RetryJitter(t *testing.T) {
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:")
+	config.Retry.Jitter = "random"
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid retry jitter mode")
+}
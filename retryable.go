@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// RetryableFunc reports whether a failed connection attempt should be retried.
+type RetryableFunc func(err error) bool
+
+// Permanent MySQL server errors: access denied and unknown database.
+var mysqlPermanentErrors = map[uint16]bool{
+	1044: true, // ER_DBACCESS_DENIED_ERROR
+	1045: true, // ER_ACCESS_DENIED_ERROR
+	1049: true, // ER_BAD_DB_ERROR
+	1251: true, // ER_NOT_SUPPORTED_AUTH_MODE
+	1698: true, // ER_ACCESS_DENIED_NO_PASSWORD_ERROR
+}
+
+// Permanent PostgreSQL SQLSTATE codes: authentication and unknown database.
+var postgresPermanentErrors = map[string]bool{
+	"28000": true, // invalid_authorization_specification
+	"28P01": true, // invalid_password
+	"3D000": true, // invalid_catalog_name
+}
+
+// Permanent SQL Server error numbers: login failed and unknown database.
+var sqlServerPermanentErrors = map[int32]bool{
+	18456: true, // Login failed for user
+	4060:  true, // Cannot open database requested by the login
+}
+
+// IsRetryableError is the default RetryableFunc. It reports false for errors
+// that another attempt cannot fix, so startup fails fast instead of retrying
+// MaxAttempts times:
+//   - authentication failures and unknown databases (mysql, pgx, sqlserver)
+//   - files that cannot be opened or are not databases (sqlite)
+//   - context cancellation
+//
+// All other errors, such as refused connections and timeouts, are retryable.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return !mysqlPermanentErrors[mysqlErr.Number]
+	}
+	if errors.Is(err, mysqldriver.ErrNativePassword) ||
+		errors.Is(err, mysqldriver.ErrOldPassword) ||
+		errors.Is(err, mysqldriver.ErrCleartextPassword) ||
+		errors.Is(err, mysqldriver.ErrUnknownPlugin) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return !postgresPermanentErrors[pgErr.Code]
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrCantOpen, sqlite3.ErrNotADB, sqlite3.ErrAuth, sqlite3.ErrPerm:
+			return false
+		}
+		return true
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return !sqlServerPermanentErrors[mssqlErr.Number]
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// useMasterKey is the context key for forcing reads to master.
+	useMasterKey contextKey = "dgcore:use_master"
+	// useReplicaKey is the context key for pinning reads to a named replica.
+	useReplicaKey contextKey = "dgcore:use_replica"
+)
+
+// UseMaster returns a context that routes reads to master with automatic routing.
+// Unlike Manager.Master, it works for code that only has a shared *gorm.DB:
+//
+//	repo.FindOrder(database.UseMaster(ctx), id) // db.WithContext(ctx).First(...)
+func UseMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, useMasterKey, true)
+}
+
+// UseReplica returns a context that routes reads to the named replica with
+// automatic routing. The name is the replica name of a slave (ConnectionConfig.Name,
+// or slave_<index> in Config.Slaves). Writes still go to master. If the replica
+// is not connected, reads fall back to master.
+func UseReplica(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, useReplicaKey, name)
+}
+
+// SkipRouting returns a context that disables automatic routing: queries run on
+// the connection of the *gorm.DB they are issued on.
+func SkipRouting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipRoutingKey, true)
+}
+
+// routingSkipped reports whether ctx was created with SkipRouting.
+func routingSkipped(ctx context.Context) bool {
+	v, ok := ctx.Value(skipRoutingKey).(bool)
+	return ok && v
+}
+
+// masterRequested reports whether ctx was created with UseMaster.
+func masterRequested(ctx context.Context) bool {
+	v, ok := ctx.Value(useMasterKey).(bool)
+	return ok && v
+}
+
+// requestedReplica returns the replica name set with UseReplica, if any.
+func requestedReplica(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(useReplicaKey).(string)
+	return name, ok
+}
+
+// slaveByName returns the connection of the named replica, or nil if it is
+// unknown, not connected or draining.
+func (m *Manager) slaveByName(name string) *gorm.DB {
+	r := m.replicaByName(name)
+	if r == nil {
+		return nil
+	}
+
+	m.slaveMu.Lock()
+	defer m.slaveMu.Unlock()
+	if r.state != ReplicaConnected {
+		return nil
+	}
+	return r.db
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newHintManager creates a manager whose master and two slaves each hold a
+// single row naming the database, so a read shows where it was routed.
+func newHintManager(t *testing.T) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		AutoRouting:        true,
+		Slaves: []ConnectionConfig{
+			{FilePath: filepath.Join(dir, "slave0.db")},
+			{FilePath: filepath.Join(dir, "slave1.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+
+	seed := map[string]*gorm.DB{
+		"master":  manager.Master(),
+		"slave_0": manager.replicas[0].db,
+		"slave_1": manager.replicas[1].db,
+	}
+	for name, db := range seed {
+		db = db.Session(&gorm.Session{Context: SkipRouting(context.Background())})
+		require.NoError(t, db.AutoMigrate(&stickyItem{}))
+		require.NoError(t, db.Create(&stickyItem{Name: name}).Error)
+	}
+	return manager
+}
+
+// findSource reads the row through a shared *gorm.DB, like a repository would.
+func findSource(ctx context.Context, db *gorm.DB) (string, error) {
+	var item stickyItem
+	err := db.WithContext(ctx).First(&item).Error
+	return item.Name, err
+}
+
+// TestRoutingHints tests that context hints steer reads on a shared *gorm.DB
+func TestRoutingHints(t *testing.T) {
+	manager := newHintManager(t)
+	db := manager.DB()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"use master", UseMaster(context.Background()), "master"},
+		{"use replica 0", UseReplica(context.Background(), "slave_0"), "slave_0"},
+		{"use replica 1", UseReplica(context.Background(), "slave_1"), "slave_1"},
+		{"unknown replica falls back to master", UseReplica(context.Background(), "slave_9"), "master"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 4; i++ {
+				source, err := findSource(tt.ctx, db)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, source)
+			}
+		})
+	}
+}
+
+// TestRoutingHints_WritesGoToMaster tests that UseReplica does not route writes
+func TestRoutingHints_WritesGoToMaster(t *testing.T) {
+	manager := newHintManager(t)
+	ctx := UseReplica(context.Background(), "slave_0")
+
+	require.NoError(t, manager.DB().WithContext(ctx).Create(&stickyItem{Name: "order"}).Error)
+
+	var count int64
+	require.NoError(t, manager.Master().Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+// TestSkipRouting tests that SkipRouting keeps queries on the session's connection
+func TestSkipRouting(t *testing.T) {
+	manager := newHintManager(t)
+
+	source, err := findSource(SkipRouting(context.Background()), manager.DB())
+	require.NoError(t, err)
+	assert.Equal(t, "master", source, "DB() is the master connection when routing is skipped")
+
+	source, err = findSource(context.Background(), manager.DB())
+	require.NoError(t, err)
+	assert.Contains(t, []string{"slave_0", "slave_1"}, source)
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Slave selection strategies for Config.SlaveStrategy.
+const (
+	StrategyRoundRobin     = "round-robin"
+	StrategyRandom         = "random"
+	StrategyWeighted       = "weighted"
+	StrategyLeastInUse     = "least-in-use"
+	StrategyEWMA           = "ewma"
+	StrategySmoothWeighted = "smooth-weighted"
+)
+
+// ewmaDecay is the weight of a new latency sample in the moving average.
+const ewmaDecay = 0.3
+
+// ReplicaInfo describes a replica that can serve a read.
+type ReplicaInfo struct {
+	Name                string            // Name used in stats and health checks
+	Host                string            // Replica host
+	Weight              int               // Configured weight
+	Tags                map[string]string // Configured tags
+	Stats               sql.DBStats       // Pool statistics
+	Latency             time.Duration     // Moving average of recent query and health check latency, 0 before the first sample
+	ReplicationLag      time.Duration     // Last measured replication lag
+	ConsecutiveFailures int               // Failed health checks since the last success
+}
+
+// ReplicaSelector chooses the replica that serves a read. Select is called
+// with the replicas currently in rotation (never empty) and returns the index
+// of the chosen one; an index out of range sends the read to master.
+//
+// A Manager never calls Select concurrently, so selectors keep state without
+// locking. A selector shared between Managers must synchronize itself.
+type ReplicaSelector interface {
+	Select(replicas []ReplicaInfo) int
+}
+
+// newReplicaSelector returns the built-in selector for a strategy name.
+func newReplicaSelector(strategy string) (ReplicaSelector, error) {
+	switch strategy {
+	case "", StrategyRoundRobin:
+		return &RoundRobinSelector{}, nil
+	case StrategyRandom:
+		return RandomSelector{}, nil
+	case StrategyWeighted:
+		return WeightedSelector{}, nil
+	case StrategyLeastInUse:
+		return &LeastInUseSelector{}, nil
+	case StrategyEWMA:
+		return EWMASelector{}, nil
+	case StrategySmoothWeighted:
+		return &SmoothWeightedSelector{}, nil
+	default:
+		return nil, fmt.Errorf("invalid slave strategy: %s (must be %s, %s, %s, %s, %s or %s)", strategy,
+			StrategyRoundRobin, StrategyRandom, StrategyWeighted, StrategyLeastInUse, StrategyEWMA, StrategySmoothWeighted)
+	}
+}
+
+// RoundRobinSelector cycles through the replicas.
+type RoundRobinSelector struct {
+	next int
+}
+
+// Select implements ReplicaSelector.
+func (s *RoundRobinSelector) Select(replicas []ReplicaInfo) int {
+	i := s.next % len(replicas)
+	s.next = (i + 1) % len(replicas)
+	return i
+}
+
+// RandomSelector picks a replica uniformly at random.
+type RandomSelector struct{}
+
+// Select implements ReplicaSelector.
+func (RandomSelector) Select(replicas []ReplicaInfo) int {
+	return rand.Intn(len(replicas))
+}
+
+// WeightedSelector picks a replica at random with probability proportional to
+// its weight. With no positive weights it picks the first replica.
+type WeightedSelector struct{}
+
+// Select implements ReplicaSelector.
+func (WeightedSelector) Select(replicas []ReplicaInfo) int {
+	totalWeight := 0
+	for _, replica := range replicas {
+		totalWeight += max(replica.Weight, 0)
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+
+	r := rand.Intn(totalWeight)
+	cumulative := 0
+	for i, replica := range replicas {
+		cumulative += max(replica.Weight, 0)
+		if r < cumulative {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// LeastInUseSelector picks the replica with the fewest connections in use.
+// Ties are broken round-robin so idle replicas share the load.
+type LeastInUseSelector struct {
+	next int
+}
+
+// Select implements ReplicaSelector.
+func (s *LeastInUseSelector) Select(replicas []ReplicaInfo) int {
+	start := s.next % len(replicas)
+	s.next = (start + 1) % len(replicas)
+
+	best := start
+	for n := 1; n < len(replicas); n++ {
+		i := (start + n) % len(replicas)
+		if replicas[i].Stats.InUse < replicas[best].Stats.InUse {
+			best = i
+		}
+	}
+	return best
+}
+
+// EWMASelector picks the replica with the lowest latency moving average,
+// weighted by its connections in use so a fast replica is not overloaded.
+// Replicas without latency samples are tried first.
+type EWMASelector struct{}
+
+// Select implements ReplicaSelector.
+func (EWMASelector) Select(replicas []ReplicaInfo) int {
+	best, bestCost := 0, ewmaCost(replicas[0])
+	for i := 1; i < len(replicas); i++ {
+		if cost := ewmaCost(replicas[i]); cost < bestCost {
+			best, bestCost = i, cost
+		}
+	}
+	return best
+}
+
+func ewmaCost(replica ReplicaInfo) float64 {
+	return float64(replica.Latency) * float64(replica.Stats.InUse+1)
+}
+
+// SmoothWeightedSelector is the smooth weighted round-robin used by nginx: it
+// follows the configured weights exactly over a cycle and interleaves the
+// replicas instead of sending bursts to the heaviest one. Replicas without a
+// positive weight count as weight 1.
+type SmoothWeightedSelector struct {
+	current map[string]int
+}
+
+// Select implements ReplicaSelector.
+func (s *SmoothWeightedSelector) Select(replicas []ReplicaInfo) int {
+	if s.current == nil {
+		s.current = make(map[string]int)
+	}
+
+	best, total := 0, 0
+	for i, replica := range replicas {
+		weight := max(replica.Weight, 1)
+		total += weight
+		s.current[replica.Name] += weight
+		if s.current[replica.Name] > s.current[replicas[best].Name] {
+			best = i
+		}
+	}
+
+	s.current[replicas[best].Name] -= total
+	return best
+}
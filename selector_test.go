@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selectMany runs a selector n times and counts the picks per replica name.
+func selectMany(selector ReplicaSelector, replicas []ReplicaInfo, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[replicas[selector.Select(replicas)].Name]++
+	}
+	return counts
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	replicas := []ReplicaInfo{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	selector := &RoundRobinSelector{}
+
+	var picks []int
+	for i := 0; i < 6; i++ {
+		picks = append(picks, selector.Select(replicas))
+	}
+	assert.Equal(t, []int{0, 1, 2, 0, 1, 2}, picks)
+
+	// Fewer candidates after an ejection stay in range
+	assert.Less(t, selector.Select(replicas[:1]), 1)
+}
+
+func TestWeightedSelector(t *testing.T) {
+	replicas := []ReplicaInfo{{Name: "a", Weight: 3}, {Name: "b", Weight: 0}, {Name: "c", Weight: 1}}
+
+	counts := selectMany(WeightedSelector{}, replicas, 400)
+	assert.Zero(t, counts["b"], "zero weight replica should not be picked")
+	assert.Greater(t, counts["a"], counts["c"])
+
+	assert.Equal(t, 0, WeightedSelector{}.Select([]ReplicaInfo{{Name: "a"}, {Name: "b"}}))
+}
+
+func TestLeastInUseSelector(t *testing.T) {
+	replicas := []ReplicaInfo{
+		{Name: "a", Stats: sql.DBStats{InUse: 4}},
+		{Name: "b", Stats: sql.DBStats{InUse: 1}},
+		{Name: "c", Stats: sql.DBStats{InUse: 3}},
+	}
+	counts := selectMany(&LeastInUseSelector{}, replicas, 6)
+	assert.Equal(t, map[string]int{"b": 6}, counts)
+
+	// Ties are shared
+	idle := []ReplicaInfo{{Name: "a"}, {Name: "b"}}
+	counts = selectMany(&LeastInUseSelector{}, idle, 6)
+	assert.Equal(t, map[string]int{"a": 3, "b": 3}, counts)
+}
+
+func TestEWMASelector(t *testing.T) {
+	replicas := []ReplicaInfo{
+		{Name: "slow", Latency: 40 * time.Millisecond},
+		{Name: "fast", Latency: 5 * time.Millisecond},
+	}
+	assert.Equal(t, 1, EWMASelector{}.Select(replicas))
+
+	// A busy fast replica loses to an idle slower one
+	replicas[1].Stats.InUse = 10
+	assert.Equal(t, 0, EWMASelector{}.Select(replicas))
+
+	// Replicas without samples are tried first
+	replicas = append(replicas, ReplicaInfo{Name: "new"})
+	assert.Equal(t, 2, EWMASelector{}.Select(replicas))
+}
+
+func TestSmoothWeightedSelector(t *testing.T) {
+	replicas := []ReplicaInfo{{Name: "a", Weight: 5}, {Name: "b", Weight: 1}, {Name: "c", Weight: 1}}
+	selector := &SmoothWeightedSelector{}
+
+	var picks string
+	for i := 0; i < 7; i++ {
+		picks += replicas[selector.Select(replicas)].Name
+	}
+	// nginx sequence for weights 5, 1, 1
+	assert.Equal(t, "aabacaa", picks)
+}
+
+func TestSlaveEWMA(t *testing.T) {
+	health := &slaveHealth{}
+	health.observe(100 * time.Millisecond)
+	assert.Equal(t, 100*time.Millisecond, health.ewma)
+
+	health.observe(0)
+	assert.Equal(t, 70*time.Millisecond, health.ewma)
+}
+
+func TestConfigValidate_SlaveStrategy(t *testing.T) {
+	config := DefaultConfig().WithDriver("sqlite").WithDatabase(":memory:")
+
+	for _, strategy := range []string{"", StrategyRoundRobin, StrategyRandom, StrategyWeighted,
+		StrategyLeastInUse, StrategyEWMA, StrategySmoothWeighted} {
+		assert.NoError(t, config.WithSlaveStrategy(strategy).Validate(), strategy)
+	}
+
+	err := config.WithSlaveStrategy("fastest").Validate()
+	assert.ErrorContains(t, err, "invalid slave strategy: fastest")
+}
+
+// lastSelector always picks the last replica and remembers what it was given.
+type lastSelector struct {
+	seen []ReplicaInfo
+}
+
+func (s *lastSelector) Select(replicas []ReplicaInfo) int {
+	s.seen = replicas
+	return len(replicas) - 1
+}
+
+// TestManager_ReplicaSelector tests that the manager passes replica metadata to a custom selector
+func TestManager_ReplicaSelector(t *testing.T) {
+	dir := t.TempDir()
+	selector := &lastSelector{}
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		AutoRouting:        true,
+		ReplicaSelector:    selector,
+		Slaves: []ConnectionConfig{
+			{Host: "replica-a", FilePath: filepath.Join(dir, "slave_a.db"), Weight: 2},
+			{Host: "replica-b", FilePath: filepath.Join(dir, "slave_b.db"), Weight: 5},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	for _, r := range manager.replicas {
+		require.NoError(t, r.db.AutoMigrate(&stickyItem{}))
+	}
+
+	var items []stickyItem
+	require.NoError(t, manager.DB().Find(&items).Error)
+
+	require.Len(t, selector.seen, 2)
+	assert.Equal(t, ReplicaInfo{Name: "slave_1", Host: "replica-b", Weight: 5}, ReplicaInfo{
+		Name: selector.seen[1].Name, Host: selector.seen[1].Host, Weight: selector.seen[1].Weight,
+	})
+
+	// The routed read was measured for slave_1 only
+	assert.Zero(t, manager.replicas[0].ewma)
+	assert.NotZero(t, manager.replicas[1].ewma)
+
+	require.NoError(t, manager.DB().Find(&items).Error)
+	assert.NotZero(t, selector.seen[1].Latency)
+	assert.Same(t, manager.replicas[1].db, manager.selectSlave())
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ErrShardNotFound is returned when a shard function has no shard for a key.
+var ErrShardNotFound = errors.New("shard not found")
+
+// ShardFunc returns the index in ShardGroup.Connections of the shard holding
+// key, given the number of shards in the group. It is called concurrently and must
+// not modify shared state. HashShard, RangeShard and LookupShard cover the
+// common schemes; custom implementations can be set as ShardGroup.ShardFunc.
+type ShardFunc func(key any, shards int) (int, error)
+
+// ShardGroup is a set of identical databases that split data by a shard key.
+// Each shard is a named connection in Config.Connections, so shards inherit
+// settings like other named connections and can have their own slaves.
+type ShardGroup struct {
+	Connections []string  // Named connections holding the shards, in shard index order
+	ShardFunc   ShardFunc // Maps a shard key to a shard (default: HashShard)
+}
+
+// shardFunc returns the shard function of the group.
+func (g ShardGroup) shardFunc() ShardFunc {
+	if g.ShardFunc != nil {
+		return g.ShardFunc
+	}
+	return HashShard
+}
+
+// HashShard spreads keys evenly over the shards by the FNV-1a hash of the
+// key. Strings and byte slices are hashed as is; other keys are hashed in
+// their fmt.Sprint form, so the integer 42 and the string "42" share a shard.
+// Adding a shard moves most keys to another shard.
+func HashShard(key any, shards int) (int, error) {
+	h := fnv.New32a()
+	switch k := key.(type) {
+	case string:
+		_, _ = h.Write([]byte(k))
+	case []byte:
+		_, _ = h.Write(k)
+	default:
+		_, _ = fmt.Fprint(h, k)
+	}
+	return int(h.Sum32() % uint32(shards)), nil
+}
+
+// RangeShard returns a ShardFunc for integer keys split into ranges. Shard i
+// holds the keys below bounds[i] and at or above bounds[i-1]; the last shard
+// holds the keys at or above the last bound, so a group has len(bounds)+1
+// shards. The bounds must be ascending.
+//
+// Example:
+//
+//	// shard_0: ids below 1000000, shard_1: up to 1999999, shard_2: the rest
+//	database.RangeShard(1000000, 2000000)
+func RangeShard(bounds ...int64) ShardFunc {
+	bounds = append([]int64(nil), bounds...)
+	return func(key any, shards int) (int, error) {
+		if shards != len(bounds)+1 {
+			return 0, fmt.Errorf("range shard has %d bounds for %d shards", len(bounds), shards)
+		}
+		k, err := shardInt(key)
+		if err != nil {
+			return 0, err
+		}
+		return sort.Search(len(bounds), func(i int) bool { return k < bounds[i] }), nil
+	}
+}
+
+// shardInt converts an integer shard key to int64.
+func shardInt(key any) (int64, error) {
+	switch k := key.(type) {
+	case int:
+		return int64(k), nil
+	case int8:
+		return int64(k), nil
+	case int16:
+		return int64(k), nil
+	case int32:
+		return int64(k), nil
+	case int64:
+		return k, nil
+	case uint:
+		return shardUint(uint64(k))
+	case uint8:
+		return int64(k), nil
+	case uint16:
+		return int64(k), nil
+	case uint32:
+		return int64(k), nil
+	case uint64:
+		return shardUint(k)
+	default:
+		return 0, fmt.Errorf("range shard key must be an integer, got %T", key)
+	}
+}
+
+// shardUint converts an unsigned shard key to int64.
+func shardUint(k uint64) (int64, error) {
+	if k > math.MaxInt64 {
+		return 0, fmt.Errorf("range shard key %d is out of range", k)
+	}
+	return int64(k), nil
+}
+
+// LookupShard returns a ShardFunc that looks up the shard index of a key in
+// table, by the key's fmt.Sprint form. Keys missing from the table fail with
+// ErrShardNotFound. The table must not be modified afterwards; use a custom
+// ShardFunc for a directory that changes at runtime.
+//
+// Example:
+//
+//	database.LookupShard(map[string]int{"acme": 0, "globex": 1})
+func LookupShard(table map[string]int) ShardFunc {
+	return func(key any, shards int) (int, error) {
+		index, ok := table[fmt.Sprint(key)]
+		if !ok {
+			return 0, fmt.Errorf("%w: key %v", ErrShardNotFound, key)
+		}
+		return index, nil
+	}
+}
+
+// validateShards checks that every shard group names configured connections.
+func (c Config) validateShards() error {
+	for name, group := range c.Shards {
+		if len(group.Connections) == 0 {
+			return fmt.Errorf("shard group %s: no connections", name)
+		}
+		for _, conn := range group.Connections {
+			if _, ok := c.Connections[conn]; !ok {
+				return fmt.Errorf("shard group %s: connection not found: %s", name, conn)
+			}
+		}
+	}
+	return nil
+}
+
+// ========== Sharding ==========
+
+// shardGroup returns a configured shard group.
+func (m *Manager) shardGroup(group string) (ShardGroup, error) {
+	m.configMu.RLock()
+	g, ok := m.config.Shards[group]
+	m.configMu.RUnlock()
+
+	if !ok {
+		return ShardGroup{}, fmt.Errorf("shard group not found: %s", group)
+	}
+	return g, nil
+}
+
+// ShardName returns the named connection of the shard holding key in a shard
+// group.
+func (m *Manager) ShardName(group string, key any) (string, error) {
+	g, err := m.shardGroup(group)
+	if err != nil {
+		return "", err
+	}
+
+	index, err := g.shardFunc()(key, len(g.Connections))
+	if err != nil {
+		return "", fmt.Errorf("shard group %s: %w", group, err)
+	}
+	if index < 0 || index >= len(g.Connections) {
+		return "", fmt.Errorf("shard group %s: %w: index %d", group, ErrShardNotFound, index)
+	}
+	return g.Connections[index], nil
+}
+
+// Shard returns the connection of the shard holding key in a shard group.
+// Shards are named connections, so lazy shards are opened on first use and
+// shards with slaves route reads like Connection.
+//
+// Example:
+//
+//	db, err := manager.Shard("customers", customerID)
+//	if err != nil {
+//	    return err
+//	}
+//	db.Where("customer_id = ?", customerID).Find(&orders)
+func (m *Manager) Shard(group string, key any) (*gorm.DB, error) {
+	if m.closed.Load() {
+		return nil, ErrManagerClosed
+	}
+
+	name, err := m.ShardName(group, key)
+	if err != nil {
+		return nil, err
+	}
+	return m.ConnectionE(name)
+}
+
+// EachShard runs fn concurrently on every shard of a shard group and waits for
+// all of them. fn receives the shard's connection name and its connection
+// bound to a context derived from ctx. The first error cancels that context
+// for the other shards and is returned with the shard name; no shard runs if
+// a shard connection is unavailable.
+//
+// Example:
+//
+//	err := manager.EachShard(ctx, "customers", func(ctx context.Context, shard string, db *gorm.DB) error {
+//	    return db.Exec("DELETE FROM sessions WHERE expires_at < ?", time.Now()).Error
+//	})
+func (m *Manager) EachShard(ctx context.Context, group string, fn func(ctx context.Context, shard string, db *gorm.DB) error) error {
+	g, err := m.shardGroup(group)
+	if err != nil {
+		return err
+	}
+	return m.runShards(ctx, g, func(ctx context.Context, i int, db *gorm.DB) error {
+		return fn(ctx, g.Connections[i], db)
+	})
+}
+
+// GatherShards runs query concurrently on every shard of a shard group, like
+// Manager.EachShard, and returns the results of all shards in shard order.
+//
+// Example:
+//
+//	orders, err := database.GatherShards(ctx, manager, "customers",
+//	    func(ctx context.Context, db *gorm.DB) ([]Order, error) {
+//	        var orders []Order
+//	        err := db.Where("status = ?", "open").Find(&orders).Error
+//	        return orders, err
+//	    })
+func GatherShards[T any](ctx context.Context, m *Manager, group string, query func(ctx context.Context, db *gorm.DB) ([]T, error)) ([]T, error) {
+	g, err := m.shardGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]T, len(g.Connections))
+	err = m.runShards(ctx, g, func(ctx context.Context, i int, db *gorm.DB) error {
+		rows, err := query(ctx, db)
+		results[i] = rows
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var merged []T
+	for _, rows := range results {
+		merged = append(merged, rows...)
+	}
+	return merged, nil
+}
+
+// runShards runs fn concurrently for every shard of g with its index; see
+// EachShard.
+func (m *Manager) runShards(ctx context.Context, g ShardGroup, fn func(ctx context.Context, i int, db *gorm.DB) error) error {
+	shards := make([]*gorm.DB, len(g.Connections))
+	for i, name := range g.Connections {
+		db, err := m.ConnectionE(name)
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", name, err)
+		}
+		shards[i] = db
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, db := range shards {
+		wg.Add(1)
+		go func(i int, db *gorm.DB) {
+			defer wg.Done()
+			if err := fn(ctx, i, db.WithContext(ctx)); err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("shard %s: %w", g.Connections[i], err)
+					cancel()
+				})
+			}
+		}(i, db)
+	}
+	wg.Wait()
+
+	return firstErr
+}
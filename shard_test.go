@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newShardManager creates a manager with a "customers" shard group of three
+// shards, each holding a row naming the shard.
+func newShardManager(t *testing.T, shardFunc ShardFunc) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:      "sqlite",
+		FilePath:    filepath.Join(dir, "primary.db"),
+		Connections: map[string]ConnectionConfig{},
+		Shards: map[string]ShardGroup{
+			"customers": {ShardFunc: shardFunc},
+		},
+	}
+	group := config.Shards["customers"]
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("shard_%d", i)
+		config.Connections[name] = ConnectionConfig{FilePath: filepath.Join(dir, name+".db")}
+		group.Connections = append(group.Connections, name)
+	}
+	config.Shards["customers"] = group
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+
+	for _, name := range group.Connections {
+		db := manager.Connection(name)
+		require.NoError(t, db.AutoMigrate(&stickyItem{}))
+		require.NoError(t, db.Create(&stickyItem{Name: name}).Error)
+	}
+	return manager
+}
+
+// TestHashShard tests that hash sharding is stable and uses every shard
+func TestHashShard(t *testing.T) {
+	seen := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		index, err := HashShard(i, 4)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, index, 0)
+		require.Less(t, index, 4)
+		seen[index] = true
+	}
+	assert.Len(t, seen, 4)
+
+	a, _ := HashShard("customer-42", 8)
+	b, _ := HashShard([]byte("customer-42"), 8)
+	assert.Equal(t, a, b)
+
+	a, _ = HashShard(42, 8)
+	b, _ = HashShard("42", 8)
+	assert.Equal(t, a, b)
+}
+
+// TestRangeShard tests range sharding on integer keys
+func TestRangeShard(t *testing.T) {
+	shard := RangeShard(100, 200)
+
+	tests := []struct {
+		key  any
+		want int
+	}{
+		{int(-5), 0},
+		{int64(99), 0},
+		{uint(100), 1},
+		{int32(199), 1},
+		{uint64(200), 2},
+		{int8(127), 1},
+	}
+	for _, tt := range tests {
+		index, err := shard(tt.key, 3)
+		require.NoError(t, err, "%v", tt.key)
+		assert.Equal(t, tt.want, index, "%v", tt.key)
+	}
+
+	_, err := shard("100", 3)
+	assert.EqualError(t, err, "range shard key must be an integer, got string")
+
+	_, err = shard(uint64(1<<63), 3)
+	assert.Error(t, err)
+
+	_, err = shard(1, 4)
+	assert.EqualError(t, err, "range shard has 2 bounds for 4 shards")
+}
+
+// TestLookupShard tests lookup table sharding
+func TestLookupShard(t *testing.T) {
+	shard := LookupShard(map[string]int{"acme": 1, "7": 2})
+
+	index, err := shard("acme", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	index, err = shard(7, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+
+	_, err = shard("globex", 3)
+	assert.ErrorIs(t, err, ErrShardNotFound)
+}
+
+// TestManager_Shard tests that Shard returns the connection of the shard holding a key
+func TestManager_Shard(t *testing.T) {
+	manager := newShardManager(t, LookupShard(map[string]int{"acme": 0, "globex": 2, "broken": 5}))
+
+	db, err := manager.Shard("customers", "globex")
+	require.NoError(t, err)
+	assert.Same(t, manager.Connection("shard_2"), db)
+
+	name, err := manager.ShardName("customers", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "shard_0", name)
+
+	_, err = manager.Shard("customers", "initech")
+	assert.ErrorIs(t, err, ErrShardNotFound)
+	assert.ErrorContains(t, err, "shard group customers")
+
+	// A shard function returning an index out of range
+	_, err = manager.Shard("customers", "broken")
+	assert.ErrorIs(t, err, ErrShardNotFound)
+
+	_, err = manager.Shard("orders", "acme")
+	assert.EqualError(t, err, "shard group not found: orders")
+
+	require.NoError(t, manager.Close())
+	_, err = manager.Shard("customers", "acme")
+	assert.ErrorIs(t, err, ErrManagerClosed)
+}
+
+// TestManager_EachShard tests that EachShard runs on every shard concurrently
+func TestManager_EachShard(t *testing.T) {
+	manager := newShardManager(t, nil)
+
+	var running, shards atomic.Int32
+	release := make(chan struct{})
+	err := manager.EachShard(context.Background(), "customers", func(ctx context.Context, shard string, db *gorm.DB) error {
+		// Every shard runs before any finishes
+		if running.Add(1) == 3 {
+			close(release)
+		}
+		<-release
+
+		var item stickyItem
+		if err := db.First(&item).Error; err != nil {
+			return err
+		}
+		if item.Name != shard {
+			return fmt.Errorf("read %s from shard %s", item.Name, shard)
+		}
+		shards.Add(1)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), shards.Load())
+}
+
+// TestManager_EachShard_Error tests that the first error cancels the other shards
+func TestManager_EachShard_Error(t *testing.T) {
+	manager := newShardManager(t, nil)
+
+	failed := errors.New("shard failed")
+	err := manager.EachShard(context.Background(), "customers", func(ctx context.Context, shard string, db *gorm.DB) error {
+		if shard == "shard_1" {
+			return failed
+		}
+		<-ctx.Done()
+		return nil
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.EqualError(t, err, "shard shard_1: shard failed")
+
+	// An unavailable shard fails before any shard runs
+	require.NoError(t, manager.RemoveConnection("shard_2"))
+
+	var ran atomic.Bool
+	err = manager.EachShard(context.Background(), "customers", func(ctx context.Context, shard string, db *gorm.DB) error {
+		ran.Store(true)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrConnectionNotFound)
+	assert.False(t, ran.Load())
+}
+
+// TestGatherShards tests that results of every shard are merged in shard order
+func TestGatherShards(t *testing.T) {
+	manager := newShardManager(t, nil)
+
+	items, err := GatherShards(context.Background(), manager, "customers", func(ctx context.Context, db *gorm.DB) ([]stickyItem, error) {
+		var items []stickyItem
+		err := db.Find(&items).Error
+		return items, err
+	})
+	require.NoError(t, err)
+
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	assert.Equal(t, []string{"shard_0", "shard_1", "shard_2"}, names)
+
+	// Counts can be summed by the caller
+	counts, err := GatherShards(context.Background(), manager, "customers", func(ctx context.Context, db *gorm.DB) ([]int64, error) {
+		var count int64
+		err := db.Model(&stickyItem{}).Count(&count).Error
+		return []int64{count}, err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1, 1}, counts)
+
+	_, err = GatherShards(context.Background(), manager, "orders", func(ctx context.Context, db *gorm.DB) ([]int64, error) {
+		return nil, nil
+	})
+	assert.EqualError(t, err, "shard group not found: orders")
+}
+
+// TestConfigValidate_Shards tests that shard groups must name configured connections
+func TestConfigValidate_Shards(t *testing.T) {
+	config := Config{
+		Driver:      "sqlite",
+		Database:    ":memory:",
+		Connections: map[string]ConnectionConfig{"shard_0": {Database: ":memory:"}},
+	}
+
+	config.Shards = map[string]ShardGroup{"customers": {Connections: []string{"shard_0"}}}
+	assert.NoError(t, config.Validate())
+
+	config.Shards = map[string]ShardGroup{"customers": {Connections: []string{"shard_0", "shard_1"}}}
+	assert.EqualError(t, config.Validate(), "shard group customers: connection not found: shard_1")
+
+	config.Shards = map[string]ShardGroup{"customers": {}}
+	assert.EqualError(t, config.Validate(), "shard group customers: no connections")
+}
+
+// TestManager_Reload_Shards tests that Reload changes shard groups
+func TestManager_Reload_Shards(t *testing.T) {
+	manager := newShardManager(t, nil)
+
+	next := manager.settings()
+	next.Shards = map[string]ShardGroup{
+		"customers": {Connections: []string{"shard_2", "shard_0"}, ShardFunc: RangeShard(10)},
+	}
+	require.NoError(t, manager.Reload(next))
+
+	name, err := manager.ShardName("customers", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "shard_2", name)
+
+	name, err = manager.ShardName("customers", 20)
+	require.NoError(t, err)
+	assert.Equal(t, "shard_0", name)
+}
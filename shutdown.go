@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrManagerClosed is returned by operations on a Manager after Close or Shutdown.
+var ErrManagerClosed = errors.New("database manager is closed")
+
+// shutdownPollInterval is how often Shutdown checks whether the pools are idle.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown closes the Manager gracefully. It stops handing out connections:
+// DB, Read, Slave, Connection and the other accessors return a connection on
+// which every operation fails with ErrManagerClosed. It then waits until no
+// connection of any pool is in use, or ctx is done, and closes every pool once:
+// the primary, a distinct master, the slaves and the named connections.
+//
+// A *gorm.DB obtained before Shutdown keeps working until its pool is closed,
+// so requests in progress can finish. Shutdown returns the joined errors of
+// the pools that failed to close and, when ctx ended first, the number of
+// connections that were still in use. Calling Shutdown or Close again returns
+// the result of the first call.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	if err := manager.Shutdown(ctx); err != nil {
+//	    log.Printf("database shutdown: %v", err)
+//	}
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.closeOnce.Do(func() { m.closeErr = m.shutdown(ctx, true) })
+	return m.closeErr
+}
+
+// shutdown closes the Manager, waiting for the pools to become idle first
+// when drain is set.
+func (m *Manager) shutdown(ctx context.Context, drain bool) error {
+	// Stop handing out connections
+	closedErr := ErrManagerClosed
+	m.closedInit.lastErr.Store(&closedErr)
+	m.closed.Store(true)
+
+	// Stop background health checks
+	m.stopHealthMonitor()
+
+	// Wait for running lazy initializations
+	m.primaryInit.mu.Lock()
+	defer m.primaryInit.mu.Unlock()
+	m.slavesInit.mu.Lock()
+	defer m.slavesInit.mu.Unlock()
+
+	m.primaryInit.close()
+	m.slavesInit.close()
+
+	m.missingInit.close()
+
+	// A lazy named connection stores itself under connMu while holding its
+	// init lock, so the init locks are taken without holding connMu
+	m.connMu.RLock()
+	inits := make([]*lazyInit, 0, len(m.connInit))
+	for _, init := range m.connInit {
+		inits = append(inits, init)
+	}
+	m.connMu.RUnlock()
+	for _, init := range inits {
+		init.mu.Lock()
+		init.close()
+		init.mu.Unlock()
+	}
+
+	m.connMu.Lock()
+	pools := m.openPools()
+	m.connMu.Unlock()
+	pools = append(pools, m.closeTenants()...)
+
+	var errs []error
+	if drain {
+		if err := waitIdle(ctx, pools); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, pool := range pools {
+		if err := pool.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", pool.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// openPool is a connection pool of the Manager and the name used in errors.
+type openPool struct {
+	name string
+	db   *sql.DB
+}
+
+// openPools returns every open pool of the Manager once, in closing order.
+// connMu must be held.
+func (m *Manager) openPools() []openPool {
+	var pools []openPool
+	seen := make(map[*sql.DB]bool)
+	add := func(name string, db *gorm.DB) {
+		if db == nil {
+			return
+		}
+		sqlDB, err := db.DB()
+		if err != nil || seen[sqlDB] {
+			return
+		}
+		seen[sqlDB] = true
+		pools = append(pools, openPool{name: name, db: sqlDB})
+	}
+
+	add("primary connection", m.db)
+	add("master connection", m.master)
+
+	// Every registry entry with a pool, whatever its state: draining replicas
+	// are still open until their reads finish
+	for _, r := range m.replicaSnapshot() {
+		add("slave "+r.name, r.db)
+	}
+
+	for name, conn := range m.connections {
+		add("named connection "+name, conn)
+	}
+
+	for name, c := range m.clusters {
+		for _, r := range c.replicaSnapshot() {
+			add("named connection "+name+" slave "+r.name, r.db)
+		}
+	}
+
+	return pools
+}
+
+// waitIdle waits until no connection of the pools is in use or ctx is done.
+func waitIdle(ctx context.Context, pools []openPool) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		inUse := 0
+		for _, pool := range pools {
+			inUse += pool.db.Stats().InUse
+		}
+		if inUse == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d connections still in use at shutdown: %w", inUse, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// closedDB returns a connection on which every operation fails with
+// ErrManagerClosed, handed out after Close or Shutdown.
+func (m *Manager) closedDB() *gorm.DB {
+	return m.closedInit.failedDB()
+}
@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// closeErrorConn is a sqlite connection whose Close fails.
+type closeErrorConn struct {
+	driver.Conn
+}
+
+func (c closeErrorConn) Close() error {
+	_ = c.Conn.Close()
+	return errors.New("close failed")
+}
+
+type closeErrorConnector struct {
+	dsn string
+}
+
+func (c closeErrorConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return closeErrorConn{conn}, nil
+}
+
+func (c closeErrorConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// registerCloseErrorDriver registers a sqlite driver whose pools fail to close.
+func registerCloseErrorDriver(t *testing.T, name string) {
+	t.Helper()
+	RegisterDriver(name, func(config DSNBuilder) (gorm.Dialector, error) {
+		return &sqlite.Dialector{Conn: sql.OpenDB(closeErrorConnector{dsn: config.GetFilePath()})}, nil
+	})
+}
+
+// newShutdownManager creates a manager with a distinct master, a slave and a named connection.
+func newShutdownManager(t *testing.T) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "primary.db"),
+		ReadWriteSplitting: true,
+		Master:             ConnectionConfig{Host: "master", FilePath: filepath.Join(dir, "master.db")},
+		Slaves:             []ConnectionConfig{{FilePath: filepath.Join(dir, "slave.db")}},
+		Connections: map[string]ConnectionConfig{
+			"reports": {FilePath: filepath.Join(dir, "reports.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager
+}
+
+// TestManager_Shutdown_ClosesEveryPool tests that every pool, including a distinct master, is closed
+func TestManager_Shutdown_ClosesEveryPool(t *testing.T) {
+	manager := newShutdownManager(t)
+
+	pools := map[string]*gorm.DB{
+		"primary": manager.db,
+		"master":  manager.master,
+		"slave":   manager.Slave("slave_0"),
+		"reports": manager.Connection("reports"),
+	}
+	require.NotSame(t, manager.db, manager.master)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, manager.Shutdown(ctx))
+
+	for name, db := range pools {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		assert.ErrorContains(t, sqlDB.Ping(), "database is closed", name)
+	}
+
+	// Later calls return the first result
+	assert.NoError(t, manager.Shutdown(ctx))
+	assert.NoError(t, manager.Close())
+}
+
+// TestManager_Shutdown_ClosesDrainingReplica tests that a replica still draining is closed by shutdown
+func TestManager_Shutdown_ClosesDrainingReplica(t *testing.T) {
+	manager := newShutdownManager(t)
+
+	// A read routed to the slave keeps it draining
+	slave, r := manager.pickSlave()
+	require.NotNil(t, r)
+	sqlDB, err := slave.DB()
+	require.NoError(t, err)
+
+	drained := make(chan error, 1)
+	go func() { drained <- manager.DrainReplica("slave_0") }()
+	require.Eventually(t, func() bool {
+		return manager.Topology().Slaves[0].State == ReplicaDraining
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, manager.Shutdown(context.Background()))
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+
+	// Finishing the read completes the drain without another close error
+	r.reads.Done()
+	assert.NoError(t, <-drained)
+}
+
+// TestManager_Shutdown_RejectsNewWork tests that accessors fail after shutdown
+func TestManager_Shutdown_RejectsNewWork(t *testing.T) {
+	manager := newShutdownManager(t)
+	require.NoError(t, manager.Shutdown(context.Background()))
+
+	for name, db := range map[string]*gorm.DB{
+		"DB":         manager.DB(),
+		"Master":     manager.Master(),
+		"Read":       manager.Read(),
+		"Slave":      manager.Slave("slave_0"),
+		"Connection": manager.Connection("reports"),
+	} {
+		err := db.Exec("SELECT 1").Error
+		assert.ErrorIs(t, err, ErrManagerClosed, name)
+	}
+
+	assert.ErrorIs(t, manager.Ping(), ErrManagerClosed)
+	assert.ErrorIs(t, manager.AutoMigrate(&stickyItem{}), ErrManagerClosed)
+	assert.ErrorIs(t, manager.AddConnection("other", ConnectionConfig{Database: ":memory:"}), ErrManagerClosed)
+	assert.ErrorIs(t, manager.AddReplica("other", ConnectionConfig{Database: ":memory:"}), ErrManagerClosed)
+	assert.False(t, manager.IsHealthy())
+}
+
+// TestManager_Shutdown_WaitsForConnectionsInUse tests that shutdown waits for running work
+func TestManager_Shutdown_WaitsForConnectionsInUse(t *testing.T) {
+	manager := newShutdownManager(t)
+
+	tx := manager.DB().Begin()
+	require.NoError(t, tx.Error)
+	require.NoError(t, tx.Exec("CREATE TABLE items (id INTEGER)").Error)
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- manager.Shutdown(ctx)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned while a transaction was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The transaction in progress can finish
+	require.NoError(t, tx.Exec("INSERT INTO items (id) VALUES (1)").Error)
+	require.NoError(t, tx.Commit().Error)
+
+	require.NoError(t, <-done)
+}
+
+// TestManager_Shutdown_Deadline tests that shutdown closes the pools when the deadline passes
+func TestManager_Shutdown_Deadline(t *testing.T) {
+	manager := newShutdownManager(t)
+
+	tx := manager.Connection("reports").Begin()
+	require.NoError(t, tx.Error)
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := manager.Shutdown(ctx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "1 connections still in use at shutdown")
+
+	sqlDB, _ := manager.master.DB()
+	assert.ErrorContains(t, sqlDB.Ping(), "database is closed")
+}
+
+// TestManager_Close_WhileLazyConnecting tests that closing does not deadlock with a lazy connection being opened
+func TestManager_Close_WhileLazyConnecting(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	RegisterDriver("test-slow-sqlite", func(config DSNBuilder) (gorm.Dialector, error) {
+		close(entered)
+		<-release
+		return sqlite.Open(config.GetFilePath()), nil
+	})
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:   "sqlite",
+		FilePath: filepath.Join(dir, "primary.db"),
+		Lazy:     true,
+		Connections: map[string]ConnectionConfig{
+			"a": {Driver: "test-slow-sqlite", FilePath: filepath.Join(dir, "a.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+
+	connected := make(chan struct{})
+	go func() {
+		defer close(connected)
+		manager.Connection("a")
+	}()
+	<-entered
+
+	closed := make(chan error, 1)
+	go func() { closed <- manager.Close() }()
+
+	// Let Close reach the pending connection before it finishes opening
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return while a lazy connection was opening")
+	}
+	<-connected
+}
+
+// TestManager_Close_ReturnsErrors tests that close failures are returned
+func TestManager_Close_ReturnsErrors(t *testing.T) {
+	registerCloseErrorDriver(t, "test-close-error")
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:       "sqlite",
+		FilePath:     filepath.Join(dir, "primary.db"),
+		MaxIdleConns: 2, // Keep the connection idle so Close closes it
+		Connections: map[string]ConnectionConfig{
+			"broken": {Driver: "test-close-error", FilePath: filepath.Join(dir, "broken.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	require.NoError(t, manager.Connection("broken").Exec("SELECT 1").Error)
+
+	err = manager.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to close named connection broken: close failed")
+	assert.Equal(t, err, manager.Close())
+}
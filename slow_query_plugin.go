@@ -1,13 +1,26 @@
 package database
 
 import (
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// slowQueryStartKey is the statement instance key holding the query start time.
+const slowQueryStartKey = "dgcore:slow_query_start"
+
+// maxSlowQueryStack is the number of frames logged with LogStack.
+const maxSlowQueryStack = 10
+
 // SlowQueryPlugin is a GORM plugin that logs slow queries.
 type SlowQueryPlugin struct {
 	config SlowQueryConfig
 	logger Logger
+	name   string        // Connection name in logs
+	live   *liveSettings // Settings of the Manager, replaced by Reload
 }
 
 // NewSlowQueryPlugin creates a new slow query logging plugin.
@@ -25,50 +38,112 @@ func (p *SlowQueryPlugin) Name() string {
 
 // Initialize initializes the plugin by registering callbacks.
 func (p *SlowQueryPlugin) Initialize(db *gorm.DB) error {
-	if !p.config.Enabled {
+	if !p.config.Enabled && p.live == nil {
 		return nil
 	}
 
-	// Register after callbacks to measure total query time
-	// We use After callbacks so we can measure the complete execution time
+	// Time each query between a before and an after callback around the
+	// GORM callback that executes it
+	db.Callback().Query().Before("gorm:query").Register("dgcore:slow_query_start", p.startQuery)
 	db.Callback().Query().After("gorm:query").Register("dgcore:slow_query", p.logSlowQuery)
+	db.Callback().Create().Before("gorm:create").Register("dgcore:slow_query_start", p.startQuery)
 	db.Callback().Create().After("gorm:create").Register("dgcore:slow_query", p.logSlowQuery)
+	db.Callback().Update().Before("gorm:update").Register("dgcore:slow_query_start", p.startQuery)
 	db.Callback().Update().After("gorm:update").Register("dgcore:slow_query", p.logSlowQuery)
+	db.Callback().Delete().Before("gorm:delete").Register("dgcore:slow_query_start", p.startQuery)
 	db.Callback().Delete().After("gorm:delete").Register("dgcore:slow_query", p.logSlowQuery)
+	db.Callback().Raw().Before("gorm:raw").Register("dgcore:slow_query_start", p.startQuery)
 	db.Callback().Raw().After("gorm:raw").Register("dgcore:slow_query", p.logSlowQuery)
+	db.Callback().Row().Before("gorm:row").Register("dgcore:slow_query_start", p.startQuery)
+	db.Callback().Row().After("gorm:row").Register("dgcore:slow_query", p.logSlowQuery)
 
 	return nil
 }
 
+// startQuery stores the start time of a query on its statement.
+func (p *SlowQueryPlugin) startQuery(db *gorm.DB) {
+	if !p.settings().Enabled {
+		return
+	}
+	db.InstanceSet(slowQueryStartKey, time.Now())
+}
+
 // logSlowQuery logs queries that exceed the threshold.
 func (p *SlowQueryPlugin) logSlowQuery(db *gorm.DB) {
-	if !p.config.Enabled {
+	v, ok := db.InstanceGet(slowQueryStartKey)
+	if !ok || v == nil {
 		return
 	}
+	// A reused statement must not time a later query from this start
+	db.InstanceSet(slowQueryStartKey, nil)
 
-	// GORM doesn't provide built-in query timing in Statement
-	// We need to use a different approach - check if there's an error first
-	// For now, we'll log based on the execution context
-	// In production, you might want to use a custom logger or middleware
+	elapsed := time.Since(v.(time.Time))
+	config := p.settings()
+	if !config.Enabled || elapsed <= config.Threshold || p.logger == nil {
+		return
+	}
 
-	// Get SQL query
 	sql := db.Statement.SQL.String()
 	if sql == "" {
 		return // Skip if no SQL
 	}
 
-	// Since we can't reliably measure time in the After callback,
-	// we'll use a simplified approach: log all queries if threshold is very low
-	// or use GORM's built-in slow query logging via SlowThreshold in config
+	args := []interface{}{
+		"duration", elapsed,
+		"threshold", config.Threshold,
+		"sql", db.Dialector.Explain(sql, db.Statement.Vars...),
+		"rows_affected", db.RowsAffected,
+		"connection", p.name,
+	}
+
+	// The read/write plugin runs its after callbacks later, so a read routed
+	// to a slave is still marked here
+	if v, _ := db.InstanceGet(slaveReadKey); v != nil {
+		if read, ok := v.(slaveRead); ok {
+			args = append(args, "replica", read.replica.name)
+		}
+	}
+
+	args = append(args, "caller", logCaller())
+	if config.LogStack {
+		args = append(args, "stack", queryStack())
+	}
+	if db.Error != nil {
+		args = append(args, "error", db.Error)
+	}
+
+	logWarn(p.logger, "Slow query", args...)
+}
+
+// queryStack returns up to maxSlowQueryStack frames of the code that ran the
+// logged query, skipping GORM, query logging and Go runtime frames.
+func queryStack() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
 
-	// For this implementation, we'll just demonstrate the structure
-	// Real timing would require Before/After callback pairs or custom middleware
+	var b strings.Builder
+	logged := 0
+	for logged < maxSlowQueryStack {
+		frame, more := frames.Next()
+		if !queryLoggingFrame(frame.Function) && !strings.HasPrefix(frame.Function, "runtime.") {
+			if logged > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(frame.Function + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line))
+			logged++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
 
-	if p.logger != nil {
-		// This is a simplified version - in production you'd want proper timing
-		logInfo(p.logger, "Query executed",
-			"sql", sql,
-			"rows_affected", db.Statement.RowsAffected,
-			"table", db.Statement.Table)
+// settings returns the current slow query configuration.
+func (p *SlowQueryPlugin) settings() SlowQueryConfig {
+	if p.live != nil {
+		return p.live.slowQuery()
 	}
+	return p.config
 }
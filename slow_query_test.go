@@ -1,21 +1,60 @@
 package database
 
 import (
+	"context"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 // mockSlowQueryLogger is a mock logger for testing slow query logging
 type mockSlowQueryLogger struct {
 	warnings []string
+	warnArgs [][]interface{}
 	infos    []string
 }
 
 func (m *mockSlowQueryLogger) Warn(msg string, args ...interface{}) {
 	m.warnings = append(m.warnings, msg)
+	m.warnArgs = append(m.warnArgs, args)
+}
+
+// slowQueries returns the key-value pairs of the logged slow queries.
+func (m *mockSlowQueryLogger) slowQueries() []map[string]interface{} {
+	var queries []map[string]interface{}
+	for i, msg := range m.warnings {
+		if msg != "Slow query" {
+			continue
+		}
+		fields := make(map[string]interface{})
+		args := m.warnArgs[i]
+		for j := 0; j+1 < len(args); j += 2 {
+			fields[args[j].(string)] = args[j+1]
+		}
+		queries = append(queries, fields)
+	}
+	return queries
+}
+
+// slowQueryDelayKey makes delaySlowQueries wait before a query runs.
+const slowQueryDelayKey contextKey = "test:slow_query_delay"
+
+// delaySlowQueries delays the queries of db whose context carries
+// slowQueryDelayKey, between the start and end of slow query timing.
+func delaySlowQueries(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	delay := func(db *gorm.DB) {
+		if d, ok := db.Statement.Context.Value(slowQueryDelayKey).(time.Duration); ok {
+			time.Sleep(d)
+		}
+	}
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:delay", delay))
+	require.NoError(t, db.Callback().Row().Before("gorm:row").Register("test:delay", delay))
 }
 
 func (m *mockSlowQueryLogger) Info(msg string, args ...interface{}) {
@@ -104,13 +143,103 @@ func TestSlowQueryPlugin_FastQuery(t *testing.T) {
 	assert.Empty(t, logger.warnings, "Fast queries should not be logged")
 }
 
-// TestSlowQueryPlugin_SlowQuery tests that slow queries are logged
+// TestSlowQueryPlugin_SlowQuery tests that only queries over the threshold are logged
 func TestSlowQueryPlugin_SlowQuery(t *testing.T) {
-	t.Skip("Skipping slow query test - difficult to create reliably slow queries in SQLite :memory:")
-	// This test is skipped because:
-	// 1. SQLite :memory: is extremely fast
-	// 2. Creating artificially slow queries is unreliable
-	// 3. The plugin logic is tested through other tests
+	logger := &mockSlowQueryLogger{}
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithSlowQueryLogging(50 * time.Millisecond)
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	defer manager.Close()
+	delaySlowQueries(t, manager.DB())
+
+	require.NoError(t, manager.AutoMigrate(&stickyItem{}))
+	require.NoError(t, manager.DB().Create(&stickyItem{Name: "fast"}).Error)
+
+	var items []stickyItem
+	require.NoError(t, manager.DB().Where("name = ?", "fast").Find(&items).Error)
+	assert.Empty(t, logger.slowQueries(), "Fast queries should not be logged")
+
+	slow := context.WithValue(context.Background(), slowQueryDelayKey, 80*time.Millisecond)
+	require.NoError(t, manager.DB().WithContext(slow).Where("name = ?", "fast").Find(&items).Error)
+
+	queries := logger.slowQueries()
+	require.Len(t, queries, 1)
+	query := queries[0]
+	assert.GreaterOrEqual(t, query["duration"], 80*time.Millisecond)
+	assert.Equal(t, 50*time.Millisecond, query["threshold"])
+	assert.Equal(t, "SELECT * FROM `sticky_items` WHERE name = \"fast\"", query["sql"])
+	assert.Equal(t, int64(1), query["rows_affected"])
+	assert.Equal(t, "primary", query["connection"])
+	assert.Contains(t, query["caller"], "slow_query_test.go:")
+	assert.NotContains(t, query, "stack")
+}
+
+// TestSlowQueryPlugin_LogStack tests that LogStack adds the stack of the caller
+func TestSlowQueryPlugin_LogStack(t *testing.T) {
+	logger := &mockSlowQueryLogger{}
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithSlowQueryLoggingAndStack(10 * time.Millisecond)
+	config.Connections = map[string]ConnectionConfig{"analytics": {Database: ":memory:"}}
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	db := manager.Connection("analytics")
+	delaySlowQueries(t, db)
+
+	slow := context.WithValue(context.Background(), slowQueryDelayKey, 20*time.Millisecond)
+	var one int
+	require.NoError(t, db.WithContext(slow).Raw("SELECT ?", 1).Scan(&one).Error)
+
+	queries := logger.slowQueries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, "analytics", queries[0]["connection"])
+	assert.Equal(t, "SELECT 1", queries[0]["sql"])
+
+	stack, _ := queries[0]["stack"].(string)
+	assert.True(t, strings.HasPrefix(stack, "github.com/donnigundala/dg-database.TestSlowQueryPlugin_LogStack\n"), stack)
+	assert.NotContains(t, stack, "gorm.io/")
+	assert.LessOrEqual(t, strings.Count(stack, "\n\t"), maxSlowQueryStack)
+}
+
+// TestSlowQueryPlugin_RoutedRead tests that reads routed to a slave name the slave
+func TestSlowQueryPlugin_RoutedRead(t *testing.T) {
+	logger := &mockSlowQueryLogger{}
+	dir := t.TempDir()
+
+	config := DefaultConfig().
+		WithDriver("sqlite").
+		WithDatabase(":memory:").
+		WithSlowQueryLogging(time.Nanosecond).
+		WithAutoRouting(true).
+		WithConnection("analytics", ConnectionConfig{
+			FilePath: filepath.Join(dir, "analytics.db"),
+			Slaves:   []ConnectionConfig{{Name: "replica_a", FilePath: filepath.Join(dir, "replica_a.db")}},
+		})
+
+	manager, err := NewManager(config, logger)
+	require.NoError(t, err)
+	defer manager.Close()
+
+	var one int
+	require.NoError(t, manager.Connection("analytics").Raw("SELECT 1").Scan(&one).Error)
+	require.NoError(t, manager.ConnectionRead("analytics").Raw("SELECT 1").Scan(&one).Error)
+
+	queries := logger.slowQueries()
+	require.Len(t, queries, 2)
+	assert.Equal(t, "analytics", queries[0]["connection"])
+	assert.Equal(t, "replica_a", queries[0]["replica"])
+	assert.Equal(t, "analytics/replica_a", queries[1]["connection"])
+	assert.NotContains(t, queries[1], "replica")
 }
 
 // TestSlowQueryConfig_FluentAPI tests the fluent API for slow query config
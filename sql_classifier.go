@@ -0,0 +1,395 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sqlDialect holds the lexical rules that differ between databases. Getting
+// them wrong can hide a keyword inside what looks like a comment or string.
+type sqlDialect struct {
+	hashComments     bool // # starts a line comment (MySQL)
+	mysqlComments    bool // -- needs trailing whitespace; /*! ... */ is executed (MySQL)
+	nestedComments   bool // Block comments nest (PostgreSQL)
+	backslashEscapes bool // Backslash escapes in quoted strings (MySQL)
+	backticks        bool // `quoted` identifiers (MySQL, SQLite)
+	brackets         bool // [quoted] identifiers (SQL Server, SQLite)
+	dollarQuotes     bool // $tag$ ... $tag$ strings (PostgreSQL)
+}
+
+// sqlDialects maps GORM dialector names to their lexical rules. Other
+// dialectors use standard SQL rules.
+var sqlDialects = map[string]sqlDialect{
+	"mysql":     {hashComments: true, mysqlComments: true, backslashEscapes: true, backticks: true},
+	"postgres":  {nestedComments: true, dollarQuotes: true},
+	"sqlite":    {backticks: true, brackets: true},
+	"sqlserver": {brackets: true},
+}
+
+type sqlTokenKind int
+
+const (
+	sqlWord     sqlTokenKind = iota // Keyword or unquoted identifier, upper-cased
+	sqlQuoted                       // String literal or quoted identifier
+	sqlNumber                       // Numeric literal
+	sqlVariable                     // Bind parameter or variable (?, $1, :name, @x, @@x), upper-cased
+	sqlPunct                        // Any other single character
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// readStatements are the leading keywords of statements that may run on a replica.
+var readStatements = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+}
+
+// writeStatements are the data-modifying statements that can be nested in
+// a read statement, such as a data-modifying CTE or EXPLAIN ANALYZE.
+var writeStatements = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"REPLACE":  true,
+	"UPSERT":   true,
+	"TRUNCATE": true,
+}
+
+// lockHints are SQL Server table hints that take update or exclusive locks.
+var lockHints = map[string]bool{
+	"UPDLOCK":  true,
+	"XLOCK":    true,
+	"HOLDLOCK": true,
+	"TABLOCKX": true,
+}
+
+// sessionFunctions modify state or read state of the current session, so
+// they must run on the connection that did the writes.
+var sessionFunctions = map[string]bool{
+	// PostgreSQL
+	"NEXTVAL": true, "SETVAL": true, "CURRVAL": true, "LASTVAL": true,
+	"SET_CONFIG": true, "PG_NOTIFY": true, "TXID_CURRENT": true, "PG_CURRENT_XACT_ID": true,
+	"PG_ADVISORY_LOCK": true, "PG_ADVISORY_LOCK_SHARED": true,
+	"PG_ADVISORY_XACT_LOCK": true, "PG_ADVISORY_XACT_LOCK_SHARED": true,
+	"PG_TRY_ADVISORY_LOCK": true, "PG_TRY_ADVISORY_LOCK_SHARED": true,
+	"PG_TRY_ADVISORY_XACT_LOCK": true, "PG_TRY_ADVISORY_XACT_LOCK_SHARED": true,
+	"PG_ADVISORY_UNLOCK": true, "PG_ADVISORY_UNLOCK_SHARED": true, "PG_ADVISORY_UNLOCK_ALL": true,
+	// MySQL
+	"GET_LOCK": true, "RELEASE_LOCK": true, "RELEASE_ALL_LOCKS": true,
+	"LAST_INSERT_ID": true, "FOUND_ROWS": true, "ROW_COUNT": true,
+	// SQLite
+	"LAST_INSERT_ROWID": true, "CHANGES": true, "TOTAL_CHANGES": true,
+	// SQL Server
+	"SCOPE_IDENTITY": true, "IDENT_CURRENT": true,
+}
+
+// sessionVariables are SQL Server variables tied to the current session.
+var sessionVariables = map[string]bool{
+	"@@IDENTITY": true,
+	"@@ROWCOUNT": true,
+}
+
+// isReadOnlySQL reports whether sql can run on a replica. It lexes the
+// statement with the rules of the dialect and returns false for anything
+// that writes, takes row locks or depends on session state:
+//   - statements other than SELECT, WITH, VALUES, TABLE, SHOW, DESCRIBE and EXPLAIN
+//   - data-modifying CTEs and EXPLAIN ANALYZE of a write
+//   - locking reads (FOR UPDATE, FOR SHARE, LOCK IN SHARE MODE, UPDLOCK hints)
+//   - SELECT ... INTO
+//   - sequence, advisory lock and last-insert-id functions
+//
+// Scripts with several statements are read-only only if every statement is.
+func isReadOnlySQL(dialect, sql string) bool {
+	tokens := lexSQL(sql, sqlDialects[dialect])
+
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !(tokens[i].kind == sqlPunct && tokens[i].text == ";") {
+			continue
+		}
+		if i > start && !isReadOnlyStatement(tokens[start:i]) {
+			return false
+		}
+		start = i + 1
+	}
+	return true
+}
+
+// isReadOnlyStatement classifies the tokens of a single statement.
+func isReadOnlyStatement(tokens []sqlToken) bool {
+	// Skip the parentheses of a statement like (SELECT ...) UNION (SELECT ...)
+	first := 0
+	for first < len(tokens) && tokens[first].kind == sqlPunct && tokens[first].text == "(" {
+		first++
+	}
+	if first == len(tokens) || tokens[first].kind != sqlWord || !readStatements[tokens[first].text] {
+		return false
+	}
+
+	depth := 0
+	for i, token := range tokens {
+		switch token.kind {
+		case sqlPunct:
+			switch token.text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+
+		case sqlVariable:
+			if sessionVariables[token.text] {
+				return false
+			}
+
+		case sqlWord:
+			var prev, next string
+			if i > 0 && tokens[i-1].kind == sqlWord {
+				prev = tokens[i-1].text
+			}
+			call := i+1 < len(tokens) && tokens[i+1].kind == sqlPunct && tokens[i+1].text == "("
+			if i+1 < len(tokens) && tokens[i+1].kind == sqlWord {
+				next = tokens[i+1].text
+			}
+
+			switch {
+			case writeStatements[token.text] && !call:
+				// INSERT(...), REPLACE(...) and TRUNCATE(...) are string and math functions
+				return false
+			case token.text == "SHARE" && (prev == "FOR" || prev == "KEY" || prev == "IN"):
+				return false
+			case token.text == "INTO" && depth <= 0:
+				// SELECT ... INTO creates a table or assigns variables
+				return false
+			case token.text == "NEXT" && next == "VALUE":
+				return false
+			case lockHints[token.text]:
+				return false
+			case call && sessionFunctions[token.text]:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// lexSQL splits sql into tokens, dropping whitespace and comments.
+func lexSQL(sql string, d sqlDialect) []sqlToken {
+	var tokens []sqlToken
+	execComments := 0 // Open MySQL /*! ... */ comments whose contents are lexed
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+
+		switch {
+		case isSQLSpace(c):
+			i++
+
+		case c == '-' && strings.HasPrefix(sql[i:], "--") &&
+			(!d.mysqlComments || i+2 == len(sql) || isSQLSpace(sql[i+2])):
+			i = skipLine(sql, i)
+
+		case c == '#' && d.hashComments:
+			i = skipLine(sql, i)
+
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if d.mysqlComments && (strings.HasPrefix(sql[i:], "/*!") || strings.HasPrefix(sql[i:], "/*M!")) {
+				// Executable comment: lex the contents, skipping the version number
+				i += strings.IndexByte(sql[i:], '!') + 1
+				for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+					i++
+				}
+				execComments++
+				continue
+			}
+			i = skipBlockComment(sql, i, d.nestedComments)
+
+		case c == '*' && execComments > 0 && strings.HasPrefix(sql[i:], "*/"):
+			execComments--
+			i += 2
+
+		case c == '\'':
+			tokens = append(tokens, sqlToken{kind: sqlQuoted})
+			i = skipQuoted(sql, i, '\'', d.backslashEscapes)
+
+		case c == '"':
+			tokens = append(tokens, sqlToken{kind: sqlQuoted})
+			i = skipQuoted(sql, i, '"', d.backslashEscapes)
+
+		case c == '`' && d.backticks:
+			tokens = append(tokens, sqlToken{kind: sqlQuoted})
+			i = skipQuoted(sql, i, '`', false)
+
+		case c == '[' && d.brackets:
+			tokens = append(tokens, sqlToken{kind: sqlQuoted})
+			i = skipQuoted(sql, i, ']', false)
+
+		case c == '$' && d.dollarQuotes:
+			if end, ok := skipDollarQuoted(sql, i); ok {
+				tokens = append(tokens, sqlToken{kind: sqlQuoted})
+				i = end
+				continue
+			}
+			// Positional parameter such as $1
+			end := i + 1
+			for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+				end++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlVariable, text: sql[i:end]})
+			i = end
+
+		case c == '?':
+			tokens = append(tokens, sqlToken{kind: sqlVariable, text: "?"})
+			i++
+
+		case (c == '@' || c == ':') && i+1 < len(sql) && (isSQLIdentStart(sql, i+1) || sql[i+1] == '@'):
+			// @var, @@var and :name; a :: cast has no identifier after the first colon
+			end := i + 1
+			for end < len(sql) && sql[end] == '@' {
+				end++
+			}
+			end = skipIdent(sql, end)
+			tokens = append(tokens, sqlToken{kind: sqlVariable, text: strings.ToUpper(sql[i:end])})
+			i = end
+
+		case c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(sql) && (isSQLIdentByte(sql[end]) || sql[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlNumber})
+			i = end
+
+		case isSQLIdentStart(sql, i):
+			end := skipIdent(sql, i)
+			word := strings.ToUpper(sql[i:end])
+
+			// Prefixed strings: E'...' (escapes), N'...', X'...', B'...'
+			if end < len(sql) && sql[end] == '\'' && (word == "E" || word == "N" || word == "X" || word == "B") {
+				tokens = append(tokens, sqlToken{kind: sqlQuoted})
+				i = skipQuoted(sql, end, '\'', d.backslashEscapes || word == "E")
+				continue
+			}
+
+			tokens = append(tokens, sqlToken{kind: sqlWord, text: word})
+			i = end
+
+		default:
+			_, size := utf8.DecodeRuneInString(sql[i:])
+			tokens = append(tokens, sqlToken{kind: sqlPunct, text: sql[i : i+size]})
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isSQLIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+// isSQLIdentStart reports whether an unquoted identifier starts at sql[i].
+func isSQLIdentStart(sql string, i int) bool {
+	c := sql[i]
+	if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return true
+	}
+	if c < utf8.RuneSelf {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(sql[i:])
+	return unicode.IsLetter(r)
+}
+
+// skipIdent returns the end of the identifier starting at sql[i].
+func skipIdent(sql string, i int) int {
+	for i < len(sql) && isSQLIdentByte(sql[i]) {
+		i++
+	}
+	return i
+}
+
+// skipLine returns the index after the line comment starting at sql[i].
+func skipLine(sql string, i int) int {
+	if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(sql)
+}
+
+// skipBlockComment returns the index after the block comment starting at sql[i].
+// An unterminated comment runs to the end of the input.
+func skipBlockComment(sql string, i int, nested bool) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*") && (nested || depth == 0):
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// skipQuoted returns the index after the quoted string or identifier starting
+// at sql[i]. A doubled closing quote is an escaped quote.
+func skipQuoted(sql string, i int, quote byte, backslashEscapes bool) int {
+	for i++; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the index after the PostgreSQL dollar-quoted string
+// starting at sql[i], or false if sql[i] does not start one.
+func skipDollarQuoted(sql string, i int) (int, bool) {
+	end := i + 1
+	if end < len(sql) && isSQLIdentStart(sql, end) {
+		for end < len(sql) && sql[end] != '$' && isSQLIdentByte(sql[end]) {
+			end++
+		}
+	}
+	if end >= len(sql) || sql[end] != '$' {
+		return 0, false
+	}
+
+	tag := sql[i : end+1]
+	if close := strings.Index(sql[end+1:], tag); close >= 0 {
+		return end + 1 + close + len(tag), true
+	}
+	return len(sql), true
+}
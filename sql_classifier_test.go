@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sqlCase struct {
+	sql      string
+	readOnly bool
+}
+
+// commonSQLCorpus holds statements that classify the same way in every dialect.
+var commonSQLCorpus = []sqlCase{
+	// Reads
+	{"SELECT * FROM users", true},
+	{"select id from users where name = ?", true},
+	{"  \n\tSELECT 1", true},
+	{"-- list users\nSELECT * FROM users", true},
+	{"/* report */ SELECT count(*) FROM orders", true},
+	{"SELECT * FROM users WHERE note = 'please update me'", true},
+	{"SELECT * FROM users WHERE note = 'it''s; DELETE FROM users'", true},
+	{`SELECT "update" FROM "delete"`, true},
+	{"SELECT updated_at, deleted_at, inserted FROM users", true},
+	{"SELECT REPLACE(name, 'a', 'b') FROM users", true},
+	{"WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", true},
+	{"with recursive t(n) as (select 1 union all select n + 1 from t) select * from t", true},
+	{"(SELECT id FROM a) UNION (SELECT id FROM b)", true},
+	{"SELECT * FROM users WHERE id IN (SELECT user_id FROM orders)", true},
+	{"SELECT 1; SELECT 2;", true},
+	{"EXPLAIN SELECT * FROM users", true},
+	{"", true},
+
+	// Writes
+	{"INSERT INTO users (name) VALUES ('a')", false},
+	{"insert into users (name) values ('a')", false},
+	{"  -- comment\n  UPDATE users SET name = 'a'", false},
+	{"/* SELECT */ DELETE FROM users", false},
+	{"DELETE FROM users WHERE note = 'SELECT'", false},
+	{"CREATE TABLE t (id int)", false},
+	{"ALTER TABLE t ADD COLUMN name text", false},
+	{"DROP TABLE t", false},
+	{"TRUNCATE TABLE users", false},
+	{"MERGE INTO users USING staged ON users.id = staged.id WHEN MATCHED THEN UPDATE SET name = staged.name", false},
+	{"BEGIN", false},
+	{"COMMIT", false},
+	{"SELECT 1; DELETE FROM users", false},
+	{"WITH ids AS (SELECT id FROM users) DELETE FROM orders WHERE user_id IN (SELECT id FROM ids)", false},
+	{"WITH ids AS (SELECT id FROM staged) INSERT INTO users (id) SELECT id FROM ids", false},
+	{"EXPLAIN ANALYZE DELETE FROM users", false},
+	{"unknown statement", false},
+}
+
+var mysqlSQLCorpus = []sqlCase{
+	{"# list users\nSELECT * FROM users", true},
+	{"SELECT * FROM `update`", true},
+	{"SELECT * FROM users WHERE note = 'it\\'s; DELETE FROM users'", true},
+	{`SELECT * FROM users WHERE note = "a \" UPDATE"`, true},
+	{"SELECT INSERT('Quadratic', 3, 4, 'What'), TRUNCATE(1.223, 1)", true},
+	{"SELECT 1--1", true},
+	{"SHOW TABLES", true},
+	{"DESCRIBE users", true},
+	{"DESC users", true},
+	{"SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM users", true},
+	{"SELECT @@version", true},
+	{"/*!40101 SELECT 1 */", true},
+
+	{"REPLACE INTO users (id, name) VALUES (1, 'a')", false},
+	{"replace users set name = 'a'", false},
+	{"SELECT * FROM users WHERE id = 1 FOR UPDATE", false},
+	{"SELECT * FROM users WHERE id = 1 for share", false},
+	{"SELECT * FROM users WHERE id = 1 LOCK IN SHARE MODE", false},
+	{"SELECT * FROM users FOR UPDATE SKIP LOCKED", false},
+	{"SELECT id INTO @id FROM users LIMIT 1", false},
+	{"SELECT * FROM users INTO OUTFILE '/tmp/users.csv'", false},
+	{"SELECT LAST_INSERT_ID()", false},
+	{"SELECT GET_LOCK('job', 10)", false},
+	{"CALL refresh_stats()", false},
+	{"SET NAMES utf8mb4", false},
+	{"set @x = 1", false},
+	{"LOCK TABLES users WRITE", false},
+	{"LOAD DATA INFILE 'users.csv' INTO TABLE users", false},
+	{"SELECT 1 # comment\n; DELETE FROM users", false},
+	{"SELECT 1 /*!50000 FOR UPDATE */", false},
+	{"SELECT * FROM users WHERE id = 1 -- \nFOR UPDATE", false},
+}
+
+var postgresSQLCorpus = []sqlCase{
+	{"SELECT * FROM users WHERE note = $1", true},
+	{"SELECT $$ DELETE FROM users $$", true},
+	{"SELECT $body$ UPDATE users $body$ AS sql", true},
+	{"SELECT E'it\\'s; DELETE FROM users'", true},
+	{"SELECT 'C:\\' AS path, 'x'", true},
+	{"/* outer /* inner */ DELETE */ SELECT 1", true},
+	{"SELECT id::text FROM users", true},
+	{"SELECT a # b FROM bits", true},
+	{"VALUES (1), (2)", true},
+	{"TABLE users", true},
+	{"SELECT * FROM users FOR UPDATE OF users SKIP LOCKED", false},
+	{"SELECT * FROM users FOR NO KEY UPDATE", false},
+	{"SELECT * FROM users FOR SHARE", false},
+	{"SELECT * FROM users FOR KEY SHARE NOWAIT", false},
+	{"WITH moved AS (DELETE FROM queue RETURNING *) SELECT * FROM moved", false},
+	{"WITH t AS MATERIALIZED (UPDATE jobs SET state = 'running' RETURNING id) SELECT id FROM t", false},
+	{"SELECT * INTO archive FROM users", false},
+	{"SELECT nextval('users_id_seq')", false},
+	{"SELECT pg_advisory_lock(42)", false},
+	{"SELECT set_config('search_path', 'tenant', false)", false},
+	{"INSERT INTO users (name) VALUES ('a') ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name", false},
+	{"DO $$ BEGIN PERFORM 1; END $$", false},
+	{"COPY users FROM STDIN", false},
+	{"VACUUM users", false},
+	{"LISTEN events", false},
+	{"SET search_path TO tenant", false},
+	{"CALL refresh()", false},
+	{"/* a /* b */ c */ DELETE FROM users", false},
+	{"SELECT 1 # 2 FOR UPDATE", false},
+}
+
+var sqliteSQLCorpus = []sqlCase{
+	{"SELECT * FROM sqlite_master WHERE type = 'table'", true},
+	{"SELECT * FROM `delete` JOIN [update] ON 1", true},
+	{"SELECT * FROM users WHERE name = 'a' -- ; DELETE FROM users", true},
+	{"EXPLAIN QUERY PLAN SELECT * FROM users", true},
+	{"INSERT OR REPLACE INTO users (id, name) VALUES (1, 'a')", false},
+	{"REPLACE INTO users (id, name) VALUES (1, 'a')", false},
+	{"UPSERT INTO users VALUES (1)", false},
+	{"PRAGMA journal_mode = WAL", false},
+	{"ATTACH DATABASE 'other.db' AS other", false},
+	{"SELECT last_insert_rowid()", false},
+	{"SELECT changes()", false},
+	{"WITH t AS (SELECT 1 AS id) INSERT INTO users (id) SELECT id FROM t", false},
+}
+
+var sqlserverSQLCorpus = []sqlCase{
+	{"SELECT TOP 10 * FROM [users]", true},
+	{"SELECT * FROM [delete] WHERE [update] = N'insert'", true},
+	{"SELECT * FROM users WITH (NOLOCK)", true},
+	{"SELECT * FROM users WHERE note = 'it''s'", true},
+	{"SELECT * FROM users WITH (UPDLOCK, ROWLOCK) WHERE id = @p1", false},
+	{"SELECT * FROM users WITH (XLOCK)", false},
+	{"SELECT * INTO #staging FROM users", false},
+	{"SELECT SCOPE_IDENTITY()", false},
+	{"SELECT @@IDENTITY", false},
+	{"SELECT NEXT VALUE FOR order_numbers", false},
+	{"EXEC sp_refresh_stats", false},
+	{"EXECUTE sp_who", false},
+	{"WITH t AS (SELECT id FROM users) UPDATE users SET name = 'a' WHERE id IN (SELECT id FROM t)", false},
+	{"MERGE users AS target USING staged AS source ON target.id = source.id WHEN NOT MATCHED THEN INSERT (id) VALUES (source.id);", false},
+	{"DECLARE @n int; SELECT @n = 1", false},
+}
+
+func runSQLCorpus(t *testing.T, dialect string, corpus []sqlCase) {
+	t.Helper()
+	for _, tt := range corpus {
+		t.Run(tt.sql, func(t *testing.T) {
+			assert.Equal(t, tt.readOnly, isReadOnlySQL(dialect, tt.sql))
+		})
+	}
+}
+
+// TestIsReadOnlySQL runs the shared corpus against every dialect and each
+// dialect against its own corpus
+func TestIsReadOnlySQL(t *testing.T) {
+	corpora := map[string][]sqlCase{
+		"mysql":     mysqlSQLCorpus,
+		"postgres":  postgresSQLCorpus,
+		"sqlite":    sqliteSQLCorpus,
+		"sqlserver": sqlserverSQLCorpus,
+	}
+
+	for dialect, corpus := range corpora {
+		t.Run(dialect, func(t *testing.T) {
+			runSQLCorpus(t, dialect, commonSQLCorpus)
+			runSQLCorpus(t, dialect, corpus)
+		})
+	}
+
+	t.Run("unknown dialect", func(t *testing.T) {
+		runSQLCorpus(t, "custom", commonSQLCorpus)
+	})
+}
+
+// TestReadWritePlugin_RawQueries tests that raw reads go to slaves and raw writes to master
+func TestReadWritePlugin_RawQueries(t *testing.T) {
+	manager := newHintManager(t)
+	db := manager.DB()
+
+	var names []string
+	require.NoError(t, db.Raw("select name from sticky_items").Scan(&names).Error)
+	require.Len(t, names, 1)
+	assert.Contains(t, []string{"slave_0", "slave_1"}, names[0], "raw read should go to a slave")
+
+	require.NoError(t, db.Exec("INSERT INTO sticky_items (name) VALUES ('raw')").Error)
+	require.NoError(t, db.Exec(`WITH t AS (SELECT 'cte' AS name) INSERT INTO sticky_items (name) SELECT name FROM t`).Error)
+
+	var count int64
+	require.NoError(t, db.Raw("/* count */ SELECT count(*) FROM sticky_items").Scan(&count).Error)
+	assert.Equal(t, int64(1), count, "slaves did not receive the writes")
+
+	require.NoError(t, db.WithContext(UseMaster(context.Background())).Raw("SELECT count(*) FROM sticky_items").Scan(&count).Error)
+	assert.Equal(t, int64(3), count, "master received the raw insert and the data-modifying CTE")
+
+	// A raw write in a statement that read from a slave goes back to master
+	require.NoError(t, db.Raw("WITH t AS (SELECT 'again' AS name) INSERT INTO sticky_items (name) SELECT name FROM t").Scan(&names).Error)
+	require.NoError(t, manager.Master().Raw("SELECT count(*) FROM sticky_items").Scan(&count).Error)
+	assert.Equal(t, int64(4), count)
+}
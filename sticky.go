@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// stickySessionKey is the context key for read-your-writes session state.
+const stickySessionKey contextKey = "dgcore:sticky_session"
+
+// defaultStickyWindow is used when Config.StickyWindow is not set.
+const defaultStickyWindow = 5 * time.Second
+
+// stickySession records the last write made in a sticky session.
+type stickySession struct {
+	lastWrite atomic.Int64 // Unix nanoseconds, 0 before the first write
+}
+
+// WithStickySession returns a context that enables read-your-writes for
+// automatic routing. After a write through the returned context (or a GORM
+// session using it), reads with the same context go to master for
+// Config.StickyWindow, so they see the write even when slaves lag behind.
+//
+// Scope it to a unit of work such as an HTTP request:
+//
+//	ctx := database.WithStickySession(r.Context())
+//	db.WithContext(ctx).Create(&order)
+//	db.WithContext(ctx).First(&order, order.ID) // served by master
+func WithStickySession(ctx context.Context) context.Context {
+	if stickySessionFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, stickySessionKey, &stickySession{})
+}
+
+// stickySessionFrom returns the sticky session stored in ctx, or nil.
+func stickySessionFrom(ctx context.Context) *stickySession {
+	if ctx == nil {
+		return nil
+	}
+	session, _ := ctx.Value(stickySessionKey).(*stickySession)
+	return session
+}
+
+// recordWrite marks the session as having written now.
+func (s *stickySession) recordWrite() {
+	s.lastWrite.Store(time.Now().UnixNano())
+}
+
+// pinned reports whether reads should stay on master after a recent write.
+func (s *stickySession) pinned(window time.Duration) bool {
+	lastWrite := s.lastWrite.Load()
+	if lastWrite == 0 {
+		return false
+	}
+	if window <= 0 {
+		window = defaultStickyWindow
+	}
+	return time.Since(time.Unix(0, lastWrite)) < window
+}
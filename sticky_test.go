@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stickyItem struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func newStickyManager(t *testing.T, window time.Duration) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+
+	config := Config{
+		Driver:             "sqlite",
+		FilePath:           filepath.Join(dir, "master.db"),
+		ReadWriteSplitting: true,
+		AutoRouting:        true,
+		StickyWindow:       window,
+		Slaves: []ConnectionConfig{
+			{FilePath: filepath.Join(dir, "slave.db")},
+		},
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+
+	// The slave never receives the writes, like a lagging replica
+	require.NoError(t, manager.AutoMigrate(&stickyItem{}))
+	require.NoError(t, manager.replicas[0].db.AutoMigrate(&stickyItem{}))
+	return manager
+}
+
+// TestStickySession_ReadsOwnWrites tests that reads after a write go to master
+func TestStickySession_ReadsOwnWrites(t *testing.T) {
+	manager := newStickyManager(t, time.Minute)
+	ctx := WithStickySession(context.Background())
+
+	item := stickyItem{Name: "order"}
+	require.NoError(t, manager.DB().WithContext(ctx).Create(&item).Error)
+
+	var found stickyItem
+	require.NoError(t, manager.DB().WithContext(ctx).First(&found, item.ID).Error)
+	assert.Equal(t, "order", found.Name)
+
+	// Without a sticky session the read goes to the lagging slave
+	var count int64
+	require.NoError(t, manager.DB().Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+// TestStickySession_WindowExpires tests that reads return to slaves after the window
+func TestStickySession_WindowExpires(t *testing.T) {
+	manager := newStickyManager(t, 20*time.Millisecond)
+	ctx := WithStickySession(context.Background())
+
+	require.NoError(t, manager.DB().WithContext(ctx).Create(&stickyItem{Name: "order"}).Error)
+	time.Sleep(50 * time.Millisecond)
+
+	var count int64
+	require.NoError(t, manager.DB().WithContext(ctx).Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count, "read should go to the slave once the window expired")
+}
+
+// TestStickySession_NoWriteUsesSlave tests that a sticky session without writes reads from slaves
+func TestStickySession_NoWriteUsesSlave(t *testing.T) {
+	manager := newStickyManager(t, time.Minute)
+	require.NoError(t, manager.Master().Create(&stickyItem{Name: "order"}).Error)
+
+	ctx := WithStickySession(context.Background())
+	var count int64
+	require.NoError(t, manager.DB().WithContext(ctx).Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+// TestWithStickySession_Idempotent tests that nested calls share the session
+func TestWithStickySession_Idempotent(t *testing.T) {
+	ctx := WithStickySession(context.Background())
+	assert.Equal(t, ctx, WithStickySession(ctx))
+	assert.Nil(t, stickySessionFrom(context.Background()))
+}
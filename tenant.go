@@ -0,0 +1,415 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoTenant is returned by ForTenant when the context carries no tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// tenantKey is the context key for the tenant ID set with WithTenant.
+const tenantKey contextKey = "dgcore:tenant"
+
+// Tenant pool defaults.
+const (
+	defaultMaxTenantPools    = 100
+	defaultTenantIdleTimeout = 10 * time.Minute
+)
+
+// tenantIDPattern matches the tenant IDs the built-in resolvers accept, which
+// are used as schema and database names.
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// TenantResolver returns the connection of a tenant, usually the main
+// connection with another Schema (schema per tenant) or Database (database
+// per tenant). Unset fields are inherited from the main Config like for named
+// connections. It is called when the pool of a tenant is opened; ctx carries
+// the values of the context passed to ForTenant but not its cancellation, as
+// concurrent callers share the open.
+type TenantResolver func(ctx context.Context, tenant string) (ConnectionConfig, error)
+
+// TenantConfig configures the tenant pools opened by Manager.ForTenant.
+//
+// Every tenant pool has its own connections, so up to MaxPools × MaxOpenConns
+// connections can be open to the database server at once.
+type TenantConfig struct {
+	Resolver     TenantResolver // Maps a tenant to its connection
+	MaxPools     int            // Tenant pools kept open; the least recently used idle pool is closed beyond it (default: 100)
+	IdleTimeout  time.Duration  // Tenant pools unused for this long are closed (default: 10m)
+	MaxOpenConns int            // Open connections per tenant pool unless the resolver sets them (default: the main MaxOpenConns)
+}
+
+// WithTenant returns a context that selects tenant for Manager.ForTenant.
+// Set it once per request, for example in an HTTP middleware:
+//
+//	ctx := database.WithTenant(r.Context(), tenantID)
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey).(string)
+	return tenant, ok && tenant != ""
+}
+
+// SchemaPerTenant returns a TenantResolver that keeps each tenant in the
+// PostgreSQL schema prefix+tenant of the main database (search_path).
+// Tenant IDs may only contain letters, digits and underscores.
+func SchemaPerTenant(prefix string) TenantResolver {
+	return func(ctx context.Context, tenant string) (ConnectionConfig, error) {
+		if !tenantIDPattern.MatchString(tenant) {
+			return ConnectionConfig{}, fmt.Errorf("invalid tenant ID: %q", tenant)
+		}
+		return ConnectionConfig{Schema: prefix + tenant}, nil
+	}
+}
+
+// DatabasePerTenant returns a TenantResolver that keeps each tenant in the
+// database prefix+tenant on the main server. Tenant IDs may only contain
+// letters, digits and underscores.
+func DatabasePerTenant(prefix string) TenantResolver {
+	return func(ctx context.Context, tenant string) (ConnectionConfig, error) {
+		if !tenantIDPattern.MatchString(tenant) {
+			return ConnectionConfig{}, fmt.Errorf("invalid tenant ID: %q", tenant)
+		}
+		return ConnectionConfig{Database: prefix + tenant}, nil
+	}
+}
+
+// tenantPool is the connection pool of a tenant in the tenant cache.
+type tenantPool struct {
+	tenant string
+	ready  chan struct{} // Closed once the pool is opened or failed to open
+	db     *gorm.DB      // Set before ready is closed
+	err    error         // Set before ready is closed
+
+	// Guarded by tenantCache.mu
+	opened   bool
+	leases   int // Connections returned by ForTenant that are not released yet
+	lastUsed time.Time
+	elem     *list.Element
+}
+
+// tenantCache holds the open tenant pools in least recently used order.
+type tenantCache struct {
+	mu     sync.Mutex
+	pools  map[string]*tenantPool
+	lru    list.List // *tenantPool, most recently used first
+	closed bool
+
+	stop chan struct{} // Stops the idle janitor; nil until it is started
+	done chan struct{}
+}
+
+// ForTenant returns the connection of the tenant in ctx, set with WithTenant,
+// bound to ctx, and a function that releases it. The pool of a tenant is
+// opened on first use with the connection from Config.Tenants.Resolver. At
+// most Tenants.MaxPools tenant pools stay open: beyond it the least recently
+// used idle pool is closed, as are pools unused for Tenants.IdleTimeout. A pool
+// is not idle while connections are in use or a connection returned by
+// ForTenant is held, that is until release is called or ctx is done.
+// Call release once the request is finished; calling it again does nothing.
+// Fetch the connection per request instead of keeping it.
+//
+// Example:
+//
+//	db, release, err := manager.ForTenant(database.WithTenant(ctx, "acme"))
+//	if err != nil {
+//	    return err
+//	}
+//	defer release()
+//	db.Find(&users) // acme's schema or database
+func (m *Manager) ForTenant(ctx context.Context) (*gorm.DB, func(), error) {
+	if m.closed.Load() {
+		return nil, nil, ErrManagerClosed
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, nil, ErrNoTenant
+	}
+	if m.config.Tenants.Resolver == nil {
+		return nil, nil, errors.New("tenant resolver is not configured")
+	}
+
+	p, err := m.openTenant(ctx, tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, release := m.leaseTenant(ctx, p)
+	return db, release, nil
+}
+
+// leaseTenant returns the connection of an open tenant pool leased by
+// openTenant and the function ending the lease. The lease also ends when ctx
+// is done, as the connection bound to ctx cannot run queries after that.
+func (m *Manager) leaseTenant(ctx context.Context, p *tenantPool) (*gorm.DB, func()) {
+	var once sync.Once
+	release := func() { once.Do(func() { m.releaseTenant(p) }) }
+	stop := context.AfterFunc(ctx, release)
+	return p.db.WithContext(ctx), func() {
+		stop()
+		release()
+	}
+}
+
+// releaseTenant ends a lease taken by openTenant and closes the pools beyond
+// MaxPools that were kept for it.
+func (m *Manager) releaseTenant(p *tenantPool) {
+	c := &m.tenants
+
+	c.mu.Lock()
+	p.leases--
+	var evicted []*tenantPool
+	if !c.closed && c.pools[p.tenant] == p {
+		c.lru.MoveToFront(p.elem)
+		p.lastUsed = time.Now()
+		evicted = c.evict(m.maxTenantPools(), nil)
+	}
+	c.mu.Unlock()
+
+	m.closeTenantPools(evicted, "Tenant pool evicted")
+}
+
+// openTenant returns the pool of a tenant with a lease for the caller, opening
+// it if needed. Concurrent callers for the same tenant share one open.
+func (m *Manager) openTenant(ctx context.Context, tenant string) (*tenantPool, error) {
+	c := &m.tenants
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrManagerClosed
+	}
+	p, ok := c.pools[tenant]
+	if ok {
+		c.lru.MoveToFront(p.elem)
+		p.lastUsed = time.Now()
+	} else {
+		if c.pools == nil {
+			c.pools = make(map[string]*tenantPool)
+		}
+		p = &tenantPool{tenant: tenant, ready: make(chan struct{}), lastUsed: time.Now()}
+		p.elem = c.lru.PushFront(p)
+		c.pools[tenant] = p
+		if c.stop == nil {
+			m.startTenantJanitor()
+		}
+
+		// The open is shared, so it must not fail because the caller that
+		// started it gave up
+		go m.connectTenant(context.WithoutCancel(ctx), p)
+	}
+	p.leases++
+	c.mu.Unlock()
+
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		m.releaseTenant(p)
+		return nil, ctx.Err()
+	}
+	if p.err != nil {
+		m.releaseTenant(p)
+		return nil, p.err
+	}
+	return p, nil
+}
+
+// connectTenant opens the pool of a tenant added to the cache by openTenant
+// without holding the cache lock.
+func (m *Manager) connectTenant(ctx context.Context, p *tenantPool) {
+	c := &m.tenants
+
+	config, err := m.config.Tenants.Resolver(ctx, p.tenant)
+	if limit := m.config.Tenants.MaxOpenConns; limit > 0 && config.MaxOpenConns == nil {
+		config.MaxOpenConns = &limit
+	}
+	var db *gorm.DB
+	if err == nil {
+		db, err = m.connectNode(ctx, "tenant/"+p.tenant, config)
+	}
+
+	c.mu.Lock()
+	closed := c.closed
+	var evicted []*tenantPool
+	switch {
+	case closed:
+		// Shutdown collected the pools while this one was opening
+		p.err = ErrManagerClosed
+	case err != nil:
+		// The next call tries again
+		p.err = fmt.Errorf("failed to connect to tenant %s: %w", p.tenant, err)
+		c.remove(p)
+	default:
+		p.db, p.opened = db, true
+		evicted = c.evict(m.maxTenantPools(), p)
+	}
+	close(p.ready)
+	c.mu.Unlock()
+
+	switch {
+	case closed:
+		if db != nil {
+			if sqlDB, err := db.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		}
+	case err != nil:
+		m.logWarn("Failed to connect to tenant", "tenant", p.tenant, "error", p.err)
+	default:
+		m.logInfo("Tenant pool opened", "tenant", p.tenant)
+		m.closeTenantPools(evicted, "Tenant pool evicted")
+	}
+}
+
+// remove takes a pool out of the cache. mu must be held.
+func (c *tenantCache) remove(p *tenantPool) {
+	if c.pools[p.tenant] == p {
+		delete(c.pools, p.tenant)
+	}
+	c.lru.Remove(p.elem)
+}
+
+// evict takes the least recently used idle pools other than keep out of the
+// cache until at most limit pools are open. mu must be held.
+func (c *tenantCache) evict(limit int, keep *tenantPool) []*tenantPool {
+	var evicted []*tenantPool
+	for e := c.lru.Back(); e != nil && len(c.pools) > limit; {
+		p := e.Value.(*tenantPool)
+		e = e.Prev()
+		if p != keep && tenantPoolIdle(p) {
+			c.remove(p)
+			evicted = append(evicted, p)
+		}
+	}
+	return evicted
+}
+
+// idle takes the idle pools unused since before cutoff out of the cache. mu
+// must be held.
+func (c *tenantCache) idle(cutoff time.Time) []*tenantPool {
+	var idle []*tenantPool
+	for e := c.lru.Back(); e != nil; {
+		p := e.Value.(*tenantPool)
+		e = e.Prev()
+		if !p.lastUsed.Before(cutoff) {
+			break
+		}
+		if tenantPoolIdle(p) {
+			c.remove(p)
+			idle = append(idle, p)
+		}
+	}
+	return idle
+}
+
+// tenantPoolIdle reports whether an open pool can be closed: every connection
+// returned by ForTenant is released and no connection of the pool is in use.
+// tenantCache.mu must be held.
+func tenantPoolIdle(p *tenantPool) bool {
+	if !p.opened || p.leases > 0 {
+		return false
+	}
+	sqlDB, err := p.db.DB()
+	return err != nil || sqlDB.Stats().InUse == 0
+}
+
+// closeTenantPool closes the connection of a tenant pool.
+func closeTenantPool(p *tenantPool) error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// closeTenantPools closes tenant pools taken out of the cache.
+func (m *Manager) closeTenantPools(pools []*tenantPool, msg string) {
+	for _, p := range pools {
+		if err := closeTenantPool(p); err != nil {
+			m.logWarn("Failed to close tenant pool", "tenant", p.tenant, "error", err)
+			continue
+		}
+		m.logInfo(msg, "tenant", p.tenant)
+	}
+}
+
+// maxTenantPools returns Tenants.MaxPools or its default.
+func (m *Manager) maxTenantPools() int {
+	if m.config.Tenants.MaxPools > 0 {
+		return m.config.Tenants.MaxPools
+	}
+	return defaultMaxTenantPools
+}
+
+// startTenantJanitor starts closing idle tenant pools in the background.
+// tenants.mu must be held.
+func (m *Manager) startTenantJanitor() {
+	idleTimeout := m.config.Tenants.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultTenantIdleTimeout
+	}
+
+	c := &m.tenants
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	stop, done := c.stop, c.done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(max(idleTimeout/2, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				idle := c.idle(time.Now().Add(-idleTimeout))
+				c.mu.Unlock()
+				m.closeTenantPools(idle, "Idle tenant pool closed")
+			}
+		}
+	}()
+}
+
+// closeTenants stops the idle janitor and returns the open tenant pools for
+// Shutdown to close. Pools opened afterwards are closed right away.
+func (m *Manager) closeTenants() []openPool {
+	c := &m.tenants
+
+	c.mu.Lock()
+	c.closed = true
+	var pools []openPool
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		p := e.Value.(*tenantPool)
+		if !p.opened {
+			continue
+		}
+		if sqlDB, err := p.db.DB(); err == nil {
+			pools = append(pools, openPool{name: "tenant " + p.tenant, db: sqlDB})
+		}
+	}
+	c.pools = nil
+	c.lru.Init()
+	stop, done := c.stop, c.done
+	c.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return pools
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTenantManager creates a manager whose tenants each have their own sqlite
+// file, opened through a counting driver.
+func newTenantManager(t *testing.T, driver string, tenants TenantConfig) (*Manager, *atomic.Int32) {
+	t.Helper()
+	opened := registerCountingSQLiteDriver(t, driver)
+	dir := t.TempDir()
+
+	tenants.Resolver = func(ctx context.Context, tenant string) (ConnectionConfig, error) {
+		if tenant == "unknown" {
+			return ConnectionConfig{}, errors.New("tenant not provisioned")
+		}
+		return ConnectionConfig{Driver: driver, FilePath: filepath.Join(dir, tenant+".db")}, nil
+	}
+
+	config := Config{
+		Driver:       "sqlite",
+		FilePath:     filepath.Join(dir, "primary.db"),
+		MaxIdleConns: 2,
+		Tenants:      tenants,
+	}
+
+	manager, err := NewManager(config, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager, opened
+}
+
+// tenantDB returns the pool of a tenant, released when the test ends, and
+// fails the test on error.
+func tenantDB(t *testing.T, manager *Manager, tenant string) *gorm.DB {
+	t.Helper()
+	db, release := tenantRequest(t, manager, tenant)
+	t.Cleanup(release)
+	return db
+}
+
+// tenantRequest returns the connection of a tenant for a request and the
+// function that releases it.
+func tenantRequest(t *testing.T, manager *Manager, tenant string) (*gorm.DB, func()) {
+	t.Helper()
+	db, release, err := manager.ForTenant(WithTenant(context.Background(), tenant))
+	require.NoError(t, err)
+	return db, release
+}
+
+// tenantLeases returns the number of leases on the pool of a tenant.
+func tenantLeases(manager *Manager, tenant string) int {
+	manager.tenants.mu.Lock()
+	defer manager.tenants.mu.Unlock()
+	if p := manager.tenants.pools[tenant]; p != nil {
+		return p.leases
+	}
+	return 0
+}
+
+// tenantClosed reports whether the pool of db is closed.
+func tenantClosed(db *gorm.DB) bool {
+	sqlDB, err := db.DB()
+	return err == nil && sqlDB.Ping() != nil
+}
+
+// TestManager_ForTenant tests that tenant pools are opened on first use and kept apart
+func TestManager_ForTenant(t *testing.T) {
+	manager, opened := newTenantManager(t, "test-tenant-sqlite", TenantConfig{})
+	assert.Equal(t, int32(0), opened.Load())
+
+	acme := tenantDB(t, manager, "acme")
+	require.NoError(t, acme.AutoMigrate(&stickyItem{}))
+	require.NoError(t, acme.Create(&stickyItem{Name: "acme"}).Error)
+
+	globex := tenantDB(t, manager, "globex")
+	require.NoError(t, globex.AutoMigrate(&stickyItem{}))
+
+	var count int64
+	require.NoError(t, globex.Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	// The pool of a tenant is reused
+	require.NoError(t, tenantDB(t, manager, "acme").Model(&stickyItem{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, int32(2), opened.Load())
+
+	// The connection is bound to the context
+	ctx := WithTenant(context.Background(), "acme")
+	db, release, err := manager.ForTenant(ctx)
+	require.NoError(t, err)
+	defer release()
+	assert.Equal(t, ctx, db.Statement.Context)
+}
+
+// TestManager_ForTenant_Errors tests the errors of ForTenant
+func TestManager_ForTenant_Errors(t *testing.T) {
+	manager, opened := newTenantManager(t, "test-tenant-errors", TenantConfig{})
+
+	_, _, err := manager.ForTenant(context.Background())
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	_, _, err = manager.ForTenant(WithTenant(context.Background(), ""))
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	// A failed tenant is resolved again on the next call
+	_, _, err = manager.ForTenant(WithTenant(context.Background(), "unknown"))
+	assert.EqualError(t, err, "failed to connect to tenant unknown: tenant not provisioned")
+	_, _, err = manager.ForTenant(WithTenant(context.Background(), "unknown"))
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), opened.Load())
+
+	plain, err := NewManager(Config{Driver: "sqlite", Database: ":memory:"}, nil)
+	require.NoError(t, err)
+	defer plain.Close()
+	_, _, err = plain.ForTenant(WithTenant(context.Background(), "acme"))
+	assert.EqualError(t, err, "tenant resolver is not configured")
+}
+
+// TestManager_ForTenant_MaxPools tests that the least recently used idle pool is closed beyond MaxPools
+func TestManager_ForTenant_MaxPools(t *testing.T) {
+	manager, opened := newTenantManager(t, "test-tenant-lru", TenantConfig{MaxPools: 2})
+
+	acme, end := tenantRequest(t, manager, "acme")
+	end()
+	globex, end := tenantRequest(t, manager, "globex")
+	end()
+	_, end = tenantRequest(t, manager, "acme") // globex is now the least recently used
+	end()
+
+	initech, end := tenantRequest(t, manager, "initech")
+	end()
+	assert.True(t, tenantClosed(globex))
+	assert.False(t, tenantClosed(acme))
+	assert.False(t, tenantClosed(initech))
+
+	// A pool with connections in use is kept
+	acme, end = tenantRequest(t, manager, "acme")
+	tx := acme.WithContext(context.Background()).Begin()
+	require.NoError(t, tx.Error)
+	end()
+	_, end = tenantRequest(t, manager, "initech")
+	end()
+	umbrella, end := tenantRequest(t, manager, "umbrella")
+	end()
+	require.NoError(t, tx.Exec("SELECT 1").Error)
+	require.NoError(t, tx.Commit().Error)
+	assert.True(t, tenantClosed(initech))
+	assert.False(t, tenantClosed(umbrella))
+
+	// A pool fetched by a running request is kept before it runs a query
+	acme, endAcme := tenantRequest(t, manager, "acme")
+	_, end = tenantRequest(t, manager, "umbrella") // acme is now the least recently used
+	end()
+	_, end = tenantRequest(t, manager, "hooli")
+	end()
+	assert.NoError(t, acme.Exec("SELECT 1").Error)
+	assert.True(t, tenantClosed(umbrella))
+	endAcme()
+
+	// An evicted tenant is opened again
+	before := opened.Load()
+	assert.NoError(t, tenantDB(t, manager, "globex").Exec("SELECT 1").Error)
+	assert.Equal(t, before+1, opened.Load())
+}
+
+// TestManager_ForTenant_IdleTimeout tests that unused tenant pools are closed
+func TestManager_ForTenant_IdleTimeout(t *testing.T) {
+	manager, _ := newTenantManager(t, "test-tenant-idle", TenantConfig{IdleTimeout: 50 * time.Millisecond})
+
+	acme, end := tenantRequest(t, manager, "acme")
+	require.NoError(t, acme.Exec("SELECT 1").Error)
+	end()
+
+	assert.Eventually(t, func() bool { return tenantClosed(acme) }, 2*time.Second, 10*time.Millisecond)
+
+	// A pool held by a running request is not idle
+	globex, end := tenantRequest(t, manager, "globex")
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, tenantClosed(globex))
+	end()
+	assert.Eventually(t, func() bool { return tenantClosed(globex) }, 2*time.Second, 10*time.Millisecond)
+
+	// The next request opens a new pool
+	assert.NoError(t, tenantDB(t, manager, "acme").Exec("SELECT 1").Error)
+}
+
+// TestManager_ForTenant_Concurrent tests that concurrent requests for a tenant share one pool
+func TestManager_ForTenant_Concurrent(t *testing.T) {
+	manager, opened := newTenantManager(t, "test-tenant-concurrent", TenantConfig{MaxPools: 3})
+
+	tenants := []string{"acme", "globex", "initech", "umbrella", "hooli"}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(tenant string) {
+			defer wg.Done()
+			db, release, err := manager.ForTenant(WithTenant(context.Background(), tenant))
+			if assert.NoError(t, err) {
+				assert.NoError(t, db.Exec("SELECT 1").Error)
+				release()
+			}
+		}(tenants[i%len(tenants)])
+	}
+	wg.Wait()
+
+	// Pools kept for running requests are closed once the requests end
+	manager.tenants.mu.Lock()
+	assert.LessOrEqual(t, len(manager.tenants.pools), 3)
+	manager.tenants.mu.Unlock()
+	assert.GreaterOrEqual(t, opened.Load(), int32(len(tenants)))
+}
+
+// TestManager_ForTenant_Leases tests that pools stay open until the connections returned by ForTenant are released
+func TestManager_ForTenant_Leases(t *testing.T) {
+	manager, _ := newTenantManager(t, "test-tenant-leases", TenantConfig{MaxPools: 1, MaxOpenConns: 3})
+
+	acme, releaseAcme := tenantRequest(t, manager, "acme")
+	assert.Equal(t, 3, maxOpenConnections(t, acme))
+
+	// Both pools are kept beyond MaxPools while their requests run
+	globex, releaseGlobex := tenantRequest(t, manager, "globex")
+	require.NoError(t, acme.Exec("SELECT 1").Error)
+	assert.False(t, tenantClosed(globex))
+
+	// The released pool is closed at once
+	releaseAcme()
+	assert.True(t, tenantClosed(acme))
+	assert.Equal(t, 0, tenantLeases(manager, "acme"))
+
+	// Releasing again does not end another lease
+	ctx, cancel := context.WithCancel(WithTenant(context.Background(), "globex"))
+	_, _, err := manager.ForTenant(ctx)
+	require.NoError(t, err)
+	releaseGlobex()
+	releaseGlobex()
+	assert.Equal(t, 1, tenantLeases(manager, "globex"))
+	assert.False(t, tenantClosed(globex))
+
+	// The lease also ends when the context is done
+	cancel()
+	assert.Eventually(t, func() bool { return tenantLeases(manager, "globex") == 0 }, time.Second, time.Millisecond)
+}
+
+// TestManager_ForTenant_CancelledOpen tests that a caller giving up does not fail the open for others
+func TestManager_ForTenant_CancelledOpen(t *testing.T) {
+	release := make(chan struct{})
+	manager, _ := newTenantManager(t, "test-tenant-cancel", TenantConfig{})
+	resolve := manager.config.Tenants.Resolver
+	manager.config.Tenants.Resolver = func(ctx context.Context, tenant string) (ConnectionConfig, error) {
+		<-release
+		return resolve(ctx, tenant)
+	}
+
+	ctx, cancel := context.WithCancel(WithTenant(context.Background(), "acme"))
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := manager.ForTenant(ctx)
+		first <- err
+	}()
+
+	// Join the open started by the first caller
+	assert.Eventually(t, func() bool {
+		manager.tenants.mu.Lock()
+		defer manager.tenants.mu.Unlock()
+		return manager.tenants.pools["acme"] != nil
+	}, time.Second, time.Millisecond)
+	waiter := make(chan error, 1)
+	go func() {
+		db, release, err := manager.ForTenant(WithTenant(context.Background(), "acme"))
+		if err == nil {
+			err = db.Exec("SELECT 1").Error
+			release()
+		}
+		waiter <- err
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	assert.NoError(t, <-waiter)
+}
+
+// TestManager_ForTenant_Shutdown tests that Shutdown closes the tenant pools
+func TestManager_ForTenant_Shutdown(t *testing.T) {
+	manager, _ := newTenantManager(t, "test-tenant-shutdown", TenantConfig{})
+
+	acme := tenantDB(t, manager, "acme")
+	require.NoError(t, manager.Shutdown(context.Background()))
+	assert.True(t, tenantClosed(acme))
+
+	_, _, err := manager.ForTenant(WithTenant(context.Background(), "acme"))
+	assert.ErrorIs(t, err, ErrManagerClosed)
+}
+
+// TestTenantResolvers tests the schema and database per tenant resolvers
+func TestTenantResolvers(t *testing.T) {
+	ctx := context.Background()
+
+	config, err := SchemaPerTenant("tenant_")(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectionConfig{Schema: "tenant_acme"}, config)
+
+	config, err = DatabasePerTenant("app_")(ctx, "acme_2")
+	require.NoError(t, err)
+	assert.Equal(t, ConnectionConfig{Database: "app_acme_2"}, config)
+
+	for _, tenant := range []string{"acme corp", "acme;drop", "ac'me", "tenant-1"} {
+		_, err := SchemaPerTenant("")(ctx, tenant)
+		assert.Error(t, err, tenant)
+		_, err = DatabasePerTenant("")(ctx, tenant)
+		assert.Error(t, err, tenant)
+	}
+
+	// The schema becomes the PostgreSQL search_path of the tenant pool
+	main := Config{Driver: "postgres", Host: "localhost", Database: "app"}
+	resolved := main.inherit(ConnectionConfig{Schema: "tenant_acme"})
+	assert.Contains(t, buildPostgresDSN(resolved), "search_path=tenant_acme")
+}
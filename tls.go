@@ -0,0 +1,163 @@
+package database
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// TLS verify modes.
+const (
+	// TLSVerifyFull verifies the server certificate chain and host name.
+	TLSVerifyFull = "verify-full"
+	// TLSVerifyCA verifies the server certificate chain but not the host name.
+	TLSVerifyCA = "verify-ca"
+	// TLSSkipVerify encrypts the connection without verifying the server certificate.
+	TLSSkipVerify = "skip-verify"
+)
+
+// tlsVerifyMode returns the configured verify mode, defaulting to verify-full.
+func tlsVerifyMode(t TLSConfig) string {
+	if t.VerifyMode == "" {
+		return TLSVerifyFull
+	}
+	return t.VerifyMode
+}
+
+// validateTLS checks the TLS settings of a connection.
+func validateTLS(c DSNBuilder) error {
+	t := c.GetTLS()
+	if !t.Enabled {
+		return nil
+	}
+
+	switch c.GetDriver() {
+	case "sqlite":
+		return fmt.Errorf("tls is not supported for driver sqlite")
+	case "sqlserver":
+		return fmt.Errorf("tls is not supported for driver sqlserver, use Encrypt instead")
+	}
+
+	switch tlsVerifyMode(t) {
+	case TLSVerifyFull, TLSVerifyCA, TLSSkipVerify:
+	default:
+		return fmt.Errorf("invalid tls verify mode: %s", t.VerifyMode)
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls client certificate and key must be set together")
+	}
+
+	if tlsVerifyMode(t) == TLSVerifyCA && t.CAFile == "" {
+		return fmt.Errorf("tls verify mode %s requires a CA file", TLSVerifyCA)
+	}
+
+	return nil
+}
+
+// buildTLSConfig loads the certificates referenced by a TLSConfig into a *tls.Config.
+// host is used as the server name when ServerName is empty.
+func buildTLSConfig(t TLSConfig, host string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: t.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls CA file %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch tlsVerifyMode(t) {
+	case TLSSkipVerify:
+		config.InsecureSkipVerify = true
+
+	case TLSVerifyCA:
+		// Verify the chain ourselves, ignoring the host name
+		roots := config.RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCerts, roots)
+		}
+	}
+
+	return config, nil
+}
+
+// verifyCertificateChain verifies a peer certificate chain against roots without checking the host name.
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("tls: server did not present a certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("tls: failed to parse server certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// mysqlTLSConfigName returns the name under which the TLS settings of a
+// connection are registered with the MySQL driver. The name is derived from the
+// settings so identical connections share one registration.
+func mysqlTLSConfigName(c DSNBuilder) string {
+	t := c.GetTLS()
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		c.GetHost(), t.CAFile, t.CertFile, t.KeyFile, t.ServerName, tlsVerifyMode(t),
+	}, "\x00")))
+	return "dgdb-" + hex.EncodeToString(sum[:8])
+}
+
+// registerMySQLTLS registers the TLS settings of a connection with the MySQL driver.
+// It runs when a pool is opened, so rotated certificate files only apply to
+// pools opened afterwards, such as pools Reload replaces.
+func registerMySQLTLS(c DSNBuilder) error {
+	if !c.GetTLS().Enabled {
+		return nil
+	}
+
+	config, err := buildTLSConfig(c.GetTLS(), c.GetHost())
+	if err != nil {
+		return err
+	}
+
+	return mysqldriver.RegisterTLSConfig(mysqlTLSConfigName(c), config)
+}
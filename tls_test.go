@@ -0,0 +1,246 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a local certificate authority for TLS tests
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	caFile string
+	dir    string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dg-database test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	ca.caFile = ca.writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *testCA) writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(ca.dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// issue creates a leaf certificate for dnsName and returns its cert and key file paths
+func (ca *testCA) issue(t *testing.T, name, dnsName string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return ca.writePEM(t, name+".pem", "CERTIFICATE", der),
+		ca.writePEM(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+// handshake dials a TLS server presenting serverCert and returns the client handshake error
+func handshake(t *testing.T, serverCert, serverKey string, client *tls.Config) error {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", listener.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// TestBuildTLSConfig_VerifyModes tests handshakes against a server signed by a local CA
+func TestBuildTLSConfig_VerifyModes(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "server", "db.internal", x509.ExtKeyUsageServerAuth)
+	otherCA := newTestCA(t)
+
+	t.Run("verify-full with matching server name", func(t *testing.T) {
+		config, err := buildTLSConfig(TLSConfig{Enabled: true, CAFile: ca.caFile, ServerName: "db.internal"}, "127.0.0.1")
+		require.NoError(t, err)
+		assert.NoError(t, handshake(t, serverCert, serverKey, config))
+	})
+
+	t.Run("verify-full with host mismatch", func(t *testing.T) {
+		config, err := buildTLSConfig(TLSConfig{Enabled: true, CAFile: ca.caFile}, "127.0.0.1")
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, serverCert, serverKey, config))
+	})
+
+	t.Run("verify-ca ignores host name", func(t *testing.T) {
+		config, err := buildTLSConfig(TLSConfig{Enabled: true, CAFile: ca.caFile, VerifyMode: TLSVerifyCA}, "127.0.0.1")
+		require.NoError(t, err)
+		assert.NoError(t, handshake(t, serverCert, serverKey, config))
+	})
+
+	t.Run("verify-ca rejects unknown CA", func(t *testing.T) {
+		config, err := buildTLSConfig(TLSConfig{Enabled: true, CAFile: otherCA.caFile, VerifyMode: TLSVerifyCA}, "127.0.0.1")
+		require.NoError(t, err)
+		assert.Error(t, handshake(t, serverCert, serverKey, config))
+	})
+
+	t.Run("skip-verify", func(t *testing.T) {
+		config, err := buildTLSConfig(TLSConfig{Enabled: true, VerifyMode: TLSSkipVerify}, "127.0.0.1")
+		require.NoError(t, err)
+		assert.NoError(t, handshake(t, serverCert, serverKey, config))
+	})
+}
+
+// TestBuildTLSConfig_ClientCertificate tests loading a client certificate for mTLS
+func TestBuildTLSConfig_ClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	clientCert, clientKey := ca.issue(t, "client", "app", x509.ExtKeyUsageClientAuth)
+
+	config, err := buildTLSConfig(TLSConfig{
+		Enabled:  true,
+		CAFile:   ca.caFile,
+		CertFile: clientCert,
+		KeyFile:  clientKey,
+	}, "db.internal")
+	require.NoError(t, err)
+
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, "db.internal", config.ServerName)
+	assert.NotNil(t, config.RootCAs)
+
+	_, err = buildTLSConfig(TLSConfig{Enabled: true, CAFile: filepath.Join(ca.dir, "missing.pem")}, "db.internal")
+	assert.Error(t, err)
+}
+
+// TestBuildMySQLDSN_TLS tests that MySQL uses a registered TLS config name
+func TestBuildMySQLDSN_TLS(t *testing.T) {
+	ca := newTestCA(t)
+
+	config := ConnectionConfig{
+		Driver:   "mysql",
+		Host:     "db.internal",
+		Port:     3306,
+		Database: "app",
+		Username: "app",
+		TLS:      TLSConfig{Enabled: true, CAFile: ca.caFile},
+	}
+
+	require.NoError(t, registerMySQLTLS(config))
+
+	dsn := buildMySQLDSN(config)
+	assert.Contains(t, dsn, "&tls="+mysqlTLSConfigName(config))
+	assert.True(t, strings.HasPrefix(mysqlTLSConfigName(config), "dgdb-"))
+
+	// The name is stable for identical settings
+	assert.Equal(t, mysqlTLSConfigName(config), mysqlTLSConfigName(config))
+
+	config.TLS.Enabled = false
+	assert.NotContains(t, buildMySQLDSN(config), "tls=")
+}
+
+// TestBuildPostgresDSN_TLS tests the PostgreSQL sslmode and certificate mapping
+func TestBuildPostgresDSN_TLS(t *testing.T) {
+	config := ConnectionConfig{
+		Driver:   "postgres",
+		Host:     "pg.internal",
+		Port:     5432,
+		Database: "app",
+		Username: "app",
+		TLS: TLSConfig{
+			Enabled:  true,
+			CAFile:   "/etc/ssl/ca.pem",
+			CertFile: "/etc/ssl/client.pem",
+			KeyFile:  "/etc/ssl/client key.pem",
+		},
+	}
+
+	dsn := buildPostgresDSN(config)
+	assert.Contains(t, dsn, "sslmode=verify-full")
+	assert.Contains(t, dsn, "sslrootcert=/etc/ssl/ca.pem")
+	assert.Contains(t, dsn, "sslcert=/etc/ssl/client.pem")
+	assert.Contains(t, dsn, "sslkey='/etc/ssl/client key.pem'")
+
+	config.TLS.VerifyMode = TLSSkipVerify
+	assert.Contains(t, buildPostgresDSN(config), "sslmode=require")
+}
+
+// TestConfigValidate_TLS tests TLS validation
+func TestConfigValidate_TLS(t *testing.T) {
+	base := DefaultConfig().WithHost("db.internal")
+
+	assert.NoError(t, base.WithTLS(TLSConfig{CAFile: "/ca.pem"}).Validate())
+	assert.Error(t, base.WithTLS(TLSConfig{VerifyMode: "sometimes"}).Validate())
+	assert.Error(t, base.WithTLS(TLSConfig{CertFile: "/client.pem"}).Validate())
+	assert.Error(t, base.WithTLS(TLSConfig{VerifyMode: TLSVerifyCA}).Validate())
+
+	sqlite := DefaultConfig().WithDriver("sqlite").WithDatabase(":memory:").WithTLS(TLSConfig{})
+	assert.Error(t, sqlite.Validate())
+
+	// Master and slave TLS blocks are checked too
+	split := base
+	split.ReadWriteSplitting = true
+	split.Master = ConnectionConfig{Host: "master.internal", TLS: TLSConfig{Enabled: true, VerifyMode: "sometimes"}}
+	assert.ErrorContains(t, split.Validate(), "master: invalid tls verify mode")
+
+	split.Master = ConnectionConfig{Host: "master.internal"}
+	split.Slaves = []ConnectionConfig{{Host: "replica.internal", TLS: TLSConfig{Enabled: true, CertFile: "/client.pem"}}}
+	assert.ErrorContains(t, split.Validate(), "slave 0: tls client certificate and key must be set together")
+}
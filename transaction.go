@@ -85,15 +85,15 @@ func (h *TransactionHelper) BeginWithOptions(opts *sql.TxOptions) *gorm.DB {
 
 // TX returns a transaction helper for the manager.
 func (m *Manager) TX() *TransactionHelper {
-	return NewTransactionHelper(m.db)
+	return NewTransactionHelper(m.primary())
 }
 
 // WithTx runs a function within a transaction using the manager.
 func (m *Manager) WithTx(fn TransactionFunc) error {
-	return WithTransaction(m.db, fn)
+	return WithTransaction(m.primary(), fn)
 }
 
 // WithTxContext runs a function within a transaction with context.
 func (m *Manager) WithTxContext(ctx context.Context, fn TransactionFunc) error {
-	return WithTransactionContext(ctx, m.db, fn)
+	return WithTransactionContext(ctx, m.primary(), fn)
 }